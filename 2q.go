@@ -15,6 +15,11 @@ const (
 	// Default2QGhostEntries is the default ratio of ghost
 	// entries kept to track entries recently evicted
 	Default2QGhostEntries = 0.50
+
+	// Adaptive2QMinRecentRatio and Adaptive2QMaxRecentRatio bound the
+	// recent ratio of an adaptive 2Q cache
+	Adaptive2QMinRecentRatio = 0.05
+	Adaptive2QMaxRecentRatio = 0.75
 )
 
 // TwoQueueCache is a thread-safe fixed size 2Q cache.
@@ -33,7 +38,16 @@ type TwoQueueCache struct {
 	recent      simplelru.LRUCache
 	frequent    simplelru.LRUCache
 	recentEvict simplelru.LRUCache
-	lock        sync.RWMutex
+	adaptive    bool
+	stats       stats
+	ghostHits   uint64
+
+	onEvict       simplelru.EvictCallback
+	onGhostExpire func(key interface{})
+	evicted       []simplelru.Entry
+	expiredGhosts []interface{}
+	flights       flightGroup
+	lock          sync.RWMutex
 }
 
 // New2Q creates a new TwoQueueCache using the default
@@ -42,22 +56,81 @@ func New2Q(size int) (*TwoQueueCache, error) {
 	return New2QParams(size, Default2QRecentRatio, Default2QGhostEntries)
 }
 
+// New2QWithEvict creates a new TwoQueueCache using the default values for
+// the parameters and the given eviction callback. The callback is invoked,
+// outside of the lock, for entries evicted from the recent or frequent
+// queues and for entries removed by Remove or Purge. Entries promoted from
+// the recent to the frequent queue are not reported.
+func New2QWithEvict(size int, onEvict simplelru.EvictCallback) (*TwoQueueCache, error) {
+	return New2QParamsWithEvict(size, Default2QRecentRatio, Default2QGhostEntries, onEvict)
+}
+
+// New2QParamsWithEvict creates a new TwoQueueCache using the provided
+// parameter values and eviction callback. See New2QWithEvict.
+func New2QParamsWithEvict(size int, recentRatio, ghostRatio float64, onEvict simplelru.EvictCallback) (*TwoQueueCache, error) {
+	c, err := New2QParams(size, recentRatio, ghostRatio)
+	if err != nil {
+		return nil, err
+	}
+	c.onEvict = onEvict
+	return c, nil
+}
+
 // New2QParams creates a new TwoQueueCache using the provided
 // parameter values.
 func New2QParams(size int, recentRatio, ghostRatio float64) (*TwoQueueCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	if ghostRatio < 0.0 || ghostRatio > 1.0 {
+		return nil, fmt.Errorf("invalid ghost ratio")
+	}
+
+	// Determine the ghost size
+	evictSize := int(float64(size) * ghostRatio)
+
+	recentEvict, err := simplelru.NewGhost(evictSize)
+	if err != nil {
+		return nil, err
+	}
+	return New2QWithGhost(size, recentRatio, recentEvict)
+}
+
+// New2QAdaptive creates a new TwoQueueCache that tunes its recent ratio
+// from ghost list feedback, starting from the default ratios. A key
+// re-admitted from the ghost list shows the recent queue evicted it too
+// early, so the recent queue grows by one entry. A ghost entry aging out
+// without being re-admitted shows the recent queue held it long enough,
+// so the recent queue shrinks by one entry. The recent ratio is kept
+// between Adaptive2QMinRecentRatio and Adaptive2QMaxRecentRatio.
+func New2QAdaptive(size int) (*TwoQueueCache, error) {
+	c, err := New2Q(size)
+	if err != nil {
+		return nil, err
+	}
+	c.adaptive = true
+	return c, nil
+}
+
+// New2QWithGhost creates a new TwoQueueCache that tracks the entries
+// recently evicted from the recent list in the provided ghost cache,
+// letting callers choose its size and retention policy. The ghost cache
+// only ever stores nil values and should not be used elsewhere. A
+// simplelru.Ghost, as used by the other constructors, stores no values at
+// all.
+func New2QWithGhost(size int, recentRatio float64, ghost simplelru.LRUCache) (*TwoQueueCache, error) {
 	if size <= 0 {
 		return nil, fmt.Errorf("invalid size")
 	}
 	if recentRatio < 0.0 || recentRatio > 1.0 {
 		return nil, fmt.Errorf("invalid recent ratio")
 	}
-	if ghostRatio < 0.0 || ghostRatio > 1.0 {
-		return nil, fmt.Errorf("invalid ghost ratio")
+	if ghost == nil {
+		return nil, fmt.Errorf("invalid ghost cache")
 	}
 
 	// Determine the sub-sizes
 	recentSize := int(float64(size) * recentRatio)
-	evictSize := int(float64(size) * ghostRatio)
 
 	// Allocate the LRUs
 	recent, err := simplelru.NewLRU(size, nil)
@@ -68,10 +141,6 @@ func New2QParams(size int, recentRatio, ghostRatio float64) (*TwoQueueCache, err
 	if err != nil {
 		return nil, err
 	}
-	recentEvict, err := simplelru.NewLRU(evictSize, nil)
-	if err != nil {
-		return nil, err
-	}
 
 	// Initialize the cache
 	c := &TwoQueueCache{
@@ -79,7 +148,23 @@ func New2QParams(size int, recentRatio, ghostRatio float64) (*TwoQueueCache, err
 		recentSize:  recentSize,
 		recent:      recent,
 		frequent:    frequent,
-		recentEvict: recentEvict,
+		recentEvict: ghost,
+	}
+	return c, nil
+}
+
+// New2QFromLRU creates a new TwoQueueCache with the capacity of the
+// provided LRU, seeded with its entries. Every entry lands in the recent
+// list, keeping the LRU's order, and has to be accessed again to be
+// promoted. The provided LRU is left untouched.
+func New2QFromLRU(lru *simplelru.LRU, recentRatio, ghostRatio float64) (*TwoQueueCache, error) {
+	c, err := New2QParams(lru.Cap(), recentRatio, ghostRatio)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range lru.Keys() {
+		v, _ := lru.Peek(k)
+		c.recent.Add(k, v)
 	}
 	return c, nil
 }
@@ -88,9 +173,15 @@ func New2QParams(size int, recentRatio, ghostRatio float64) (*TwoQueueCache, err
 func (c *TwoQueueCache) Get(key interface{}) (value interface{}, ok bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return c.get(key)
+}
 
+// get looks up a key's value, promoting it to the frequent list.
+// It must be called with the lock held.
+func (c *TwoQueueCache) get(key interface{}) (value interface{}, ok bool) {
 	// Check if this is a frequent value
 	if val, ok := c.frequent.Get(key); ok {
+		c.stats.hits++
 		return val, ok
 	}
 
@@ -99,23 +190,137 @@ func (c *TwoQueueCache) Get(key interface{}) (value interface{}, ok bool) {
 	if val, ok := c.recent.Peek(key); ok {
 		c.recent.Remove(key)
 		c.frequent.Add(key, val)
+		c.stats.hits++
 		return val, ok
 	}
 
 	// No hit
+	c.stats.misses++
 	return nil, false
 }
 
 // Add adds a value to the cache.
 func (c *TwoQueueCache) Add(key, value interface{}) {
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.add(key, value)
+	c.unlock()
+}
+
+// GetOrAdd looks up a key's value from the cache, promoting it like Get,
+// and adds the provided value if it is not contained. A key in the ghost
+// list is re-admitted into the frequent list as with Add. Returns the
+// value now cached for the key and whether it was already contained.
+func (c *TwoQueueCache) GetOrAdd(key, value interface{}) (actual interface{}, loaded bool) {
+	c.lock.Lock()
+	if actual, ok := c.get(key); ok {
+		c.lock.Unlock()
+		return actual, true
+	}
+	c.add(key, value)
+	c.unlock()
+	return value, false
+}
+
+// AddWithEviction adds a value to the cache like Add, also returning the
+// key and value of the entry evicted to make room, if any.
+func (c *TwoQueueCache) AddWithEviction(key, value interface{}) (evicted bool, evictedKey, evictedValue interface{}) {
+	c.lock.Lock()
+	defer c.unlock()
+	evictedKey, evictedValue, evicted = c.add(key, value)
+	return evicted, evictedKey, evictedValue
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating recency
+// or frequency, and if not, adds the value as with Add. Returns whether
+// found and whether an eviction occurred.
+func (c *TwoQueueCache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if c.frequent.Contains(key) || c.recent.Contains(key) {
+		return true, false
+	}
+	_, _, evicted = c.add(key, value)
+	return false, evicted
+}
+
+// PeekOrAdd checks if a key is in the cache without updating recency or
+// frequency, and if not, adds the value as with Add. Returns the previous
+// value if found, whether found and whether an eviction occurred.
+func (c *TwoQueueCache) PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if previous, ok = c.frequent.Peek(key); ok {
+		return previous, true, false
+	}
+	if previous, ok = c.recent.Peek(key); ok {
+		return previous, true, false
+	}
+	_, _, evicted = c.add(key, value)
+	return nil, false, evicted
+}
 
+// GetOrCompute looks up a key's value from the cache, promoting it like
+// Get, and otherwise computes the value with fn and adds it as with Add.
+// Nothing is added if fn returns an error, which is returned as is. fn runs
+// outside of the lock, and concurrent lookups of the same missing key share
+// a single call of fn, the others waiting for its result, so fn may call
+// into the cache for other keys but not for the key itself.
+func (c *TwoQueueCache) GetOrCompute(key interface{}, fn func(key interface{}) (interface{}, error)) (value interface{}, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	return c.flights.do(key, func() (interface{}, error) {
+		value, err := fn(key)
+		if err == nil {
+			c.Add(key, value)
+		}
+		return value, err
+	})
+}
+
+// OnGhostExpire registers a callback invoked with the key of each ghost
+// entry aged out of the ghost list by a newer one, without having been
+// re-admitted. A high rate of expirations shows that the ghost list is too
+// small or that the workload does not re-access evicted keys. The callback
+// is invoked outside of the lock. A nil callback unregisters it.
+func (c *TwoQueueCache) OnGhostExpire(fn func(key interface{})) {
+	c.lock.Lock()
+	c.onGhostExpire = fn
+	c.lock.Unlock()
+}
+
+// unlock releases the lock and invokes the callbacks for the entries
+// evicted and the ghost entries aged out while it was held.
+func (c *TwoQueueCache) unlock() {
+	evicted, expiredGhosts := c.evicted, c.expiredGhosts
+	onEvict, onGhostExpire := c.onEvict, c.onGhostExpire
+	c.evicted, c.expiredGhosts = nil, nil
+	c.lock.Unlock()
+	for _, e := range evicted {
+		onEvict(e.Key, e.Value)
+	}
+	for _, k := range expiredGhosts {
+		onGhostExpire(k)
+	}
+}
+
+// evict records an entry evicted or removed for the eviction callback.
+// It must be called with the lock held.
+func (c *TwoQueueCache) evict(key, value interface{}) {
+	if c.onEvict != nil {
+		c.evicted = append(c.evicted, simplelru.Entry{Key: key, Value: value})
+	}
+}
+
+// add adds a value to the cache, returning the entry evicted to make
+// room, if any. It must be called with the lock held.
+func (c *TwoQueueCache) add(key, value interface{}) (evictedKey, evictedValue interface{}, evicted bool) {
+	c.stats.adds++
 	// Check if the value is frequently used already,
 	// and just update the value
 	if c.frequent.Contains(key) {
 		c.frequent.Add(key, value)
-		return
+		return nil, nil, false
 	}
 
 	// Check if the value is recently used, and promote
@@ -123,42 +328,126 @@ func (c *TwoQueueCache) Add(key, value interface{}) {
 	if c.recent.Contains(key) {
 		c.recent.Remove(key)
 		c.frequent.Add(key, value)
-		return
+		return nil, nil, false
 	}
 
 	// If the value was recently evicted, add it to the
 	// frequently used list
 	if c.recentEvict.Contains(key) {
-		c.ensureSpace(true)
+		c.ghostHits++
+		if c.adaptive {
+			c.adaptRecentSize(1)
+		}
+		// Drop the ghost first so that making space does not
+		// age out another ghost entry in its place
 		c.recentEvict.Remove(key)
+		evictedKey, evictedValue, evicted = c.ensureSpace(true)
 		c.frequent.Add(key, value)
-		return
+		return evictedKey, evictedValue, evicted
 	}
 
 	// Add to the recently seen list
-	c.ensureSpace(false)
+	evictedKey, evictedValue, evicted = c.ensureSpace(false)
 	c.recent.Add(key, value)
+	return evictedKey, evictedValue, evicted
 }
 
-// ensureSpace is used to ensure we have space in the cache
-func (c *TwoQueueCache) ensureSpace(recentEvict bool) {
+// ensureSpace is used to ensure we have space in the cache, returning the
+// entry evicted, if any.
+func (c *TwoQueueCache) ensureSpace(recentEvict bool) (key, value interface{}, evicted bool) {
 	// If we have space, nothing to do
 	recentLen := c.recent.Len()
 	freqLen := c.frequent.Len()
 	if recentLen+freqLen < c.size {
-		return
+		return nil, nil, false
 	}
 
-	// If the recent buffer is larger than
-	// the target, evict from there
-	if recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !recentEvict)) {
-		k, _, _ := c.recent.RemoveOldest()
-		c.recentEvict.Add(k, nil)
-		return
+	// If the recent buffer is larger than the target, evict from there,
+	// as when the frequent list is empty, for instance with a recent
+	// ratio of 1.0
+	if freqLen == 0 || (recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !recentEvict))) {
+		k, v := c.evictRecent()
+		return k, v, true
 	}
 
 	// Remove from the frequent list otherwise
-	c.frequent.RemoveOldest()
+	k, v, _ := c.frequent.RemoveOldest()
+	c.stats.evictions++
+	c.evict(k, v)
+	return k, v, true
+}
+
+// evictRecent evicts the oldest entry of the recent queue, which must not
+// be empty, keeping its key in the ghost list. It must be called with the
+// lock held.
+func (c *TwoQueueCache) evictRecent() (key, value interface{}) {
+	k, v, _ := c.recent.RemoveOldest()
+	c.stats.evictions++
+	c.evict(k, v)
+	var ghost interface{}
+	if c.onGhostExpire != nil {
+		ghost, _, _ = c.recentEvict.GetOldest()
+	}
+	if c.recentEvict.Add(k, nil) {
+		if c.adaptive {
+			c.adaptRecentSize(-1)
+		}
+		if c.onGhostExpire != nil {
+			c.expiredGhosts = append(c.expiredGhosts, ghost)
+		}
+	}
+	return k, v
+}
+
+// adaptRecentSize moves the target size of the recent list by delta,
+// within the bounds of the adaptive recent ratio.
+func (c *TwoQueueCache) adaptRecentSize(delta int) {
+	minSize := int(float64(c.size) * Adaptive2QMinRecentRatio)
+	maxSize := int(float64(c.size) * Adaptive2QMaxRecentRatio)
+	size := c.recentSize + delta
+	if size < minSize {
+		size = minSize
+	}
+	if size > maxSize {
+		size = maxSize
+	}
+	c.recentSize = size
+}
+
+// SetRatios retunes the ratio of the cache dedicated to recently added
+// entries and the ratio of ghost entries of a live cache, without purging
+// it. The ghost list is trimmed right away, aged out ghosts are not
+// reported to OnGhostExpire. If the recent queue is over its new target
+// size, its oldest entries are evicted into the ghost list until it is
+// back to its target, as when making room for new entries. An adaptive
+// cache keeps adapting from the new recent ratio.
+func (c *TwoQueueCache) SetRatios(recentRatio, ghostRatio float64) error {
+	if recentRatio < 0.0 || recentRatio > 1.0 {
+		return fmt.Errorf("invalid recent ratio")
+	}
+	if ghostRatio < 0.0 || ghostRatio > 1.0 {
+		return fmt.Errorf("invalid ghost ratio")
+	}
+	c.lock.Lock()
+	defer c.unlock()
+	evictSize := int(float64(c.size) * ghostRatio)
+	if evictSize <= 0 {
+		return fmt.Errorf("invalid ghost ratio")
+	}
+	c.recentSize = int(float64(c.size) * recentRatio)
+	c.recentEvict.Resize(evictSize)
+	for c.recent.Len() > c.recentSize {
+		c.evictRecent()
+	}
+	return nil
+}
+
+// RecentRatio returns the ratio of the cache currently dedicated to
+// recently added entries. It only changes for adaptive caches.
+func (c *TwoQueueCache) RecentRatio() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return float64(c.recentSize) / float64(c.size)
 }
 
 // Len returns the number of items in the cache.
@@ -168,6 +457,58 @@ func (c *TwoQueueCache) Len() int {
 	return c.recent.Len() + c.frequent.Len()
 }
 
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity. Get,
+// GetOrAdd, GetOrCompute and the promotions committed by a batch count as
+// lookups, and only removals made to free capacity count as evictions.
+func (c *TwoQueueCache) Stats() simplelru.Stats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stats.snapshot(c.recent.Len()+c.frequent.Len(), c.size)
+}
+
+// ResetStats zeroes the counters reported by Stats and GhostHits.
+func (c *TwoQueueCache) ResetStats() {
+	c.lock.Lock()
+	c.stats = stats{}
+	c.ghostHits = 0
+	c.lock.Unlock()
+}
+
+// RecentLen returns the number of entries in the recent queue, accessed
+// once since added. Along with RecentRatio, it shows whether the recent
+// queue is kept at its target size.
+func (c *TwoQueueCache) RecentLen() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.recent.Len()
+}
+
+// FrequentLen returns the number of entries in the frequent queue,
+// accessed again after they were added.
+func (c *TwoQueueCache) FrequentLen() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.frequent.Len()
+}
+
+// GhostLen returns the number of keys tracked by the ghost list.
+func (c *TwoQueueCache) GhostLen() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.recentEvict.Len()
+}
+
+// GhostHits returns the number of keys added again while in the ghost
+// list, and so promoted straight into the frequent queue, since the cache
+// was created or ResetStats was last called. Many ghost hits relative to
+// adds show that the recent queue evicts entries too early.
+func (c *TwoQueueCache) GhostHits() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.ghostHits
+}
+
 // Keys returns a slice of the keys in the cache.
 // The frequently used keys are first in the returned slice.
 func (c *TwoQueueCache) Keys() []interface{} {
@@ -178,25 +519,122 @@ func (c *TwoQueueCache) Keys() []interface{} {
 	return append(k1, k2...)
 }
 
-// Remove removes the provided key from the cache.
+// Range calls fn for each entry in the cache, in the order of Keys, without
+// promoting the keys. It stops early if fn returns false. The read lock is
+// held throughout, so fn must not call back into the cache.
+func (c *TwoQueueCache) Range(fn func(key, value interface{}) bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if rangeLRU(c.frequent, fn) {
+		rangeLRU(c.recent, fn)
+	}
+}
+
+// rangeLRU calls fn for each entry of l, from oldest to newest, returning
+// false if fn stopped the iteration.
+func rangeLRU(l simplelru.LRUCache, fn func(key, value interface{}) bool) bool {
+	cont := true
+	if l, ok := l.(*simplelru.LRU); ok {
+		l.Range(func(key, value interface{}) bool {
+			cont = fn(key, value)
+			return cont
+		})
+		return cont
+	}
+	for _, key := range l.Keys() {
+		value, _ := l.Peek(key)
+		if !fn(key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove removes the provided key from the cache, or from the ghost list.
 func (c *TwoQueueCache) Remove(key interface{}) {
 	c.lock.Lock()
-	defer c.lock.Unlock()
-	if c.frequent.Remove(key) {
-		return
+	c.remove(key)
+	c.unlock()
+}
+
+// remove removes a key from the cache, or from the ghost list, returning
+// if it was contained in the cache. It must be called with the lock held.
+func (c *TwoQueueCache) remove(key interface{}) (present bool) {
+	if v, ok := c.frequent.Peek(key); ok {
+		c.frequent.Remove(key)
+		c.evict(key, v)
+		return true
 	}
-	if c.recent.Remove(key) {
-		return
+	if v, ok := c.recent.Peek(key); ok {
+		c.recent.Remove(key)
+		c.evict(key, v)
+		return true
 	}
-	if c.recentEvict.Remove(key) {
-		return
+	c.recentEvict.Remove(key)
+	return false
+}
+
+// Resize changes the cache size, keeping the ratio dedicated to recently
+// added entries, and returns the number of entries evicted. Sizes below 1
+// are raised to 1. The ghost list keeps its size.
+func (c *TwoQueueCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
 	}
+	c.lock.Lock()
+	defer c.unlock()
+	recentRatio := float64(c.recentSize) / float64(c.size)
+	c.size = size
+	c.recentSize = int(float64(size) * recentRatio)
+	for c.recent.Len()+c.frequent.Len() > size {
+		c.ensureSpace(false)
+		evicted++
+	}
+	c.recent.Resize(size)
+	c.frequent.Resize(size)
+	return evicted
+}
+
+// RemoveOldest removes the oldest entry of the recent queue, or of the
+// frequent queue if the recent queue is empty, and returns it. The entry
+// is not tracked by the ghost list, and the eviction callback is invoked as
+// for Remove.
+func (c *TwoQueueCache) RemoveOldest() (key, value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if key, value, ok = c.recent.RemoveOldest(); !ok {
+		key, value, ok = c.frequent.RemoveOldest()
+	}
+	if ok {
+		c.evict(key, value)
+	}
+	return key, value, ok
+}
+
+// GetOldest returns the oldest entry of the recent queue, or of the
+// frequent queue if the recent queue is empty, without updating recency
+// or frequency.
+func (c *TwoQueueCache) GetOldest() (key, value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if key, value, ok = c.recent.GetOldest(); !ok {
+		key, value, ok = c.frequent.GetOldest()
+	}
+	return key, value, ok
 }
 
 // Purge is used to completely clear the cache.
 func (c *TwoQueueCache) Purge() {
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	defer c.unlock()
+	if c.onEvict != nil {
+		for _, l := range []simplelru.LRUCache{c.recent, c.frequent} {
+			for _, k := range l.Keys() {
+				v, _ := l.Peek(k)
+				c.evict(k, v)
+			}
+		}
+	}
 	c.recent.Purge()
 	c.frequent.Purge()
 	c.recentEvict.Purge()
@@ -210,6 +648,15 @@ func (c *TwoQueueCache) Contains(key interface{}) bool {
 	return c.frequent.Contains(key) || c.recent.Contains(key)
 }
 
+// ContainsGhost is used to check if a key was recently evicted
+// from the recent queue and is still tracked by the ghost list.
+// Such keys are re-admitted straight into the frequent queue.
+func (c *TwoQueueCache) ContainsGhost(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.recentEvict.Contains(key)
+}
+
 // Peek is used to inspect the cache value of a key
 // without updating recency or frequency.
 func (c *TwoQueueCache) Peek(key interface{}) (value interface{}, ok bool) {
@@ -220,3 +667,42 @@ func (c *TwoQueueCache) Peek(key interface{}) (value interface{}, ok bool) {
 	}
 	return c.recent.Peek(key)
 }
+
+// TwoQueueBatch records the promotions of lookups made through it and
+// applies them to the cache only on Commit, so that speculative reads do
+// not pollute the frequent list. A batch is not safe for concurrent use.
+type TwoQueueBatch struct {
+	c    *TwoQueueCache
+	keys []interface{}
+}
+
+// BeginBatch starts a batch of lookups whose promotions are deferred.
+func (c *TwoQueueCache) BeginBatch() *TwoQueueBatch {
+	return &TwoQueueBatch{c: c}
+}
+
+// Get looks up a key's value without updating recency or frequency,
+// recording the key to be promoted on Commit if it was found.
+func (b *TwoQueueBatch) Get(key interface{}) (value interface{}, ok bool) {
+	value, ok = b.c.Peek(key)
+	if ok {
+		b.keys = append(b.keys, key)
+	}
+	return value, ok
+}
+
+// Commit applies the recorded promotions in the order of the lookups.
+// Keys removed from the cache since their lookup are skipped.
+func (b *TwoQueueBatch) Commit() {
+	b.c.lock.Lock()
+	for _, key := range b.keys {
+		b.c.get(key)
+	}
+	b.c.lock.Unlock()
+	b.keys = nil
+}
+
+// Abort discards the recorded promotions.
+func (b *TwoQueueBatch) Abort() {
+	b.keys = nil
+}
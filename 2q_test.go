@@ -3,6 +3,8 @@ package lru
 import (
 	"math/rand"
 	"testing"
+
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 func Benchmark2Q_Rand(b *testing.B) {
@@ -304,3 +306,562 @@ func Test2Q_Peek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// Test that ContainsGhost reports keys evicted from the recent queue
+func Test2Q_ContainsGhost(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Add(i, i)
+	}
+	if l.Contains(0) {
+		t.Fatalf("0 should have been evicted")
+	}
+	if !l.ContainsGhost(0) {
+		t.Fatalf("0 should be in the ghost list")
+	}
+	if l.ContainsGhost(4) {
+		t.Fatalf("4 should not be in the ghost list")
+	}
+
+	l.Add(0, 0)
+	if l.ContainsGhost(0) {
+		t.Fatalf("0 should have been re-admitted")
+	}
+	if !l.Contains(0) {
+		t.Fatalf("0 should be contained")
+	}
+}
+
+// Test that batched lookups only promote on Commit
+func Test2Q_Batch(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+
+	b := l.BeginBatch()
+	if v, ok := b.Get(1); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if _, ok := b.Get(9); ok {
+		t.Fatalf("should not be contained")
+	}
+	b.Abort()
+	b.Commit()
+	if n := l.frequent.Len(); n != 0 {
+		t.Fatalf("aborted lookups should not promote: %v", n)
+	}
+
+	b = l.BeginBatch()
+	b.Get(1)
+	b.Get(2)
+	if n := l.frequent.Len(); n != 0 {
+		t.Fatalf("lookups should not promote before Commit: %v", n)
+	}
+	b.Commit()
+	if !l.frequent.Contains(1) || !l.frequent.Contains(2) || l.recent.Len() != 2 {
+		t.Fatalf("bad promotion: %v", l.frequent.Keys())
+	}
+}
+
+// Test that a caller-provided ghost cache tracks recent evictions
+func Test2Q_WithGhost(t *testing.T) {
+	ghost, err := simplelru.NewLRU(1, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := New2QWithGhost(4, Default2QRecentRatio, ghost)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	if ghost.Len() != 1 || !ghost.Contains(1) {
+		t.Fatalf("bad ghost keys: %v", ghost.Keys())
+	}
+	if !l.ContainsGhost(1) || l.ContainsGhost(0) {
+		t.Fatalf("ghost should only remember the latest eviction")
+	}
+
+	if _, err := New2QWithGhost(4, Default2QRecentRatio, nil); err == nil {
+		t.Fatalf("should reject a nil ghost cache")
+	}
+}
+
+// Test that an adaptive cache tunes its recent ratio from ghost feedback
+func Test2Q_Adaptive(t *testing.T) {
+	l, err := New2QAdaptive(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r := l.RecentRatio(); r != Default2QRecentRatio {
+		t.Fatalf("bad initial ratio: %v", r)
+	}
+
+	// A scan ages out ghost entries, shrinking the recent queue
+	for i := 0; i < 200; i++ {
+		l.Add(i, i)
+	}
+	if r := l.RecentRatio(); r != Adaptive2QMinRecentRatio {
+		t.Fatalf("ratio should have shrunk to the minimum: %v", r)
+	}
+
+	// Re-admitting ghost entries grows it again
+	for i := 50; i < 60; i++ {
+		if !l.ContainsGhost(i) {
+			t.Fatalf("%v should be a ghost", i)
+		}
+		l.Add(i, i)
+	}
+	if r := l.RecentRatio(); r != 0.15 {
+		t.Fatalf("ratio should have grown: %v", r)
+	}
+
+	// Plain caches never adapt
+	l2, err := New2Q(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l2.Add(i%300, i)
+	}
+	if r := l2.RecentRatio(); r != Default2QRecentRatio {
+		t.Fatalf("ratio should not have changed: %v", r)
+	}
+}
+
+// Test that a 2Q cache can be seeded from an LRU
+func Test2Q_FromLRU(t *testing.T) {
+	lru, err := simplelru.NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		lru.Add(i, i*10)
+	}
+	lru.Get(0)
+
+	l, err := New2QFromLRU(lru, Default2QRecentRatio, Default2QGhostEntries)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Len() != 4 || l.frequent.Len() != 0 {
+		t.Fatalf("bad: %v %v", l.Len(), l.frequent.Len())
+	}
+	if v, ok := l.Peek(2); !ok || v != 20 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	// The LRU's oldest entry is evicted first
+	l.Add(4, 40)
+	if l.Contains(1) || !l.ContainsGhost(1) || !l.Contains(0) {
+		t.Fatalf("bad eviction order: %v", l.Keys())
+	}
+	if lru.Len() != 4 || !lru.Contains(1) {
+		t.Fatalf("source LRU should be untouched")
+	}
+}
+
+// Test that GetOrAdd follows the promotion and ghost re-admission rules
+func Test2Q_GetOrAdd(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, loaded := l.GetOrAdd(0, 0); loaded || v != 0 {
+		t.Fatalf("bad: %v %v", v, loaded)
+	}
+	if !l.recent.Contains(0) {
+		t.Fatalf("a miss should be added to the recent list")
+	}
+	if v, loaded := l.GetOrAdd(0, 10); !loaded || v != 0 {
+		t.Fatalf("bad: %v %v", v, loaded)
+	}
+	if !l.frequent.Contains(0) {
+		t.Fatalf("a recent hit should be promoted")
+	}
+
+	for i := 1; i < 6; i++ {
+		l.GetOrAdd(i, i)
+	}
+	if !l.ContainsGhost(1) {
+		t.Fatalf("1 should be a ghost")
+	}
+	if v, loaded := l.GetOrAdd(1, 11); loaded || v != 11 {
+		t.Fatalf("bad: %v %v", v, loaded)
+	}
+	if !l.frequent.Contains(1) || l.ContainsGhost(1) {
+		t.Fatalf("a ghost should be re-admitted into the frequent list")
+	}
+}
+
+// Test that OnGhostExpire reports ghost entries aged out unused
+func Test2Q_OnGhostExpire(t *testing.T) {
+	l, err := New2QParams(4, 0.25, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var expired []interface{}
+	l.OnGhostExpire(func(k interface{}) {
+		// The lock is not held
+		l.Len()
+		expired = append(expired, k)
+	})
+
+	// The ghost list holds 2 keys, so the third eviction ages out 0
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	if len(expired) != 0 {
+		t.Fatalf("bad expired: %v", expired)
+	}
+	l.Add(6, 6)
+	if len(expired) != 1 || expired[0] != 0 {
+		t.Fatalf("bad expired: %v", expired)
+	}
+
+	// Re-admitting a ghost does not report it
+	l.GetOrAdd(2, 2)
+	if len(expired) != 1 {
+		t.Fatalf("bad expired: %v", expired)
+	}
+
+	l.OnGhostExpire(nil)
+	for i := 7; i < 12; i++ {
+		l.Add(i, i)
+	}
+	if len(expired) != 1 {
+		t.Fatalf("bad expired: %v", expired)
+	}
+}
+
+// Test that GetOrCompute only computes missing keys
+func Test2Q_GetOrCompute(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testGetOrCompute(t, l.GetOrCompute)
+	if !l.frequent.Contains(3) {
+		t.Fatalf("a recent hit should be promoted")
+	}
+}
+
+// Test that the eviction callback reports entries leaving the cache
+func Test2Q_WithEvict(t *testing.T) {
+	var evicted []interface{}
+	l, err := New2QParamsWithEvict(4, 0.25, 0.5, func(k, v interface{}) {
+		if k != v {
+			t.Fatalf("evict values not equal (%v!=%v)", k, v)
+		}
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	// Promotions are not evictions
+	l.Get(0)
+	l.Get(1)
+	if len(evicted) != 0 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	// Each add evicts the oldest recent entry while the recent queue is
+	// above its target size: 2 and 3, then 4 to re-admit the ghost 2
+	l.Add(4, 4)
+	l.Add(5, 5)
+	l.Add(2, 2)
+	if len(evicted) != 3 || evicted[0] != 2 || evicted[1] != 3 || evicted[2] != 4 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	// The recent queue is at its target size, so re-admitting the ghost 3
+	// evicts 0 from the frequent queue
+	l.Add(3, 3)
+	if len(evicted) != 4 || evicted[3] != 0 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+
+	// Removing a ghost is not reported
+	l.Remove(1)
+	l.Remove(4)
+	if len(evicted) != 5 || evicted[4] != 1 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	l.Purge()
+	if len(evicted) != 8 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}
+
+// Test that Stats counts lookups, adds and evictions
+func Test2Q_Stats(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 6; i++ {
+		l.Get(i)
+	}
+	l.Remove(5)
+
+	st := l.Stats()
+	expected := simplelru.Stats{Hits: 4, Misses: 2, Adds: 6, Evictions: 2, Len: 3, Cap: 4, HitRatio: 4.0 / 6.0}
+	if st != expected {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	l.ResetStats()
+	if st := l.Stats(); st != (simplelru.Stats{Len: 3, Cap: 4}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+// Test that RemoveOldest prefers the recent queue
+func Test2Q_RemoveOldest(t *testing.T) {
+	var evicted []interface{}
+	l, err := New2QWithEvict(4, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, ok := l.GetOldest(); ok {
+		t.Fatalf("should be empty")
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+	l.Get(3)
+
+	for _, want := range []int{1, 2, 0, 3} {
+		if k, v, ok := l.GetOldest(); !ok || k != want || v != want {
+			t.Fatalf("bad oldest: %v %v %v", k, v, ok)
+		}
+		if k, _, ok := l.RemoveOldest(); !ok || k != want {
+			t.Fatalf("bad removed: %v %v", k, ok)
+		}
+	}
+	if _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("should be empty")
+	}
+	if len(evicted) != 4 || l.ContainsGhost(1) {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}
+
+// Test that ContainsOrAdd and PeekOrAdd only add missing keys
+func Test2Q_ContainsOrAdd(t *testing.T) {
+	l, err := New2Q(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ok, evicted := l.ContainsOrAdd(1, 1); ok || evicted {
+		t.Fatalf("bad: %v %v", ok, evicted)
+	}
+	if ok, evicted := l.ContainsOrAdd(1, 10); !ok || evicted {
+		t.Fatalf("bad: %v %v", ok, evicted)
+	}
+	if l.frequent.Contains(1) {
+		t.Fatalf("should not promote")
+	}
+	if prev, ok, evicted := l.PeekOrAdd(2, 2); prev != nil || ok || evicted {
+		t.Fatalf("bad: %v %v %v", prev, ok, evicted)
+	}
+	if prev, ok, evicted := l.PeekOrAdd(1, 10); prev != 1 || !ok || evicted {
+		t.Fatalf("bad: %v %v %v", prev, ok, evicted)
+	}
+	if prev, ok, evicted := l.PeekOrAdd(3, 3); prev != nil || ok || !evicted {
+		t.Fatalf("bad: %v %v %v", prev, ok, evicted)
+	}
+	if l.Contains(1) {
+		t.Fatalf("1 should have been evicted")
+	}
+}
+
+// Test that AddWithEviction returns the evicted entry
+func Test2Q_AddWithEviction(t *testing.T) {
+	l, err := New2QParams(2, 0.5, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if evicted, k, v := l.AddWithEviction(1, "a"); evicted || k != nil || v != nil {
+		t.Fatalf("bad: %v %v %v", evicted, k, v)
+	}
+	l.AddWithEviction(2, "b")
+	l.Get(2)
+	if evicted, k, v := l.AddWithEviction(3, "c"); !evicted || k != 1 || v != "a" {
+		t.Fatalf("bad: %v %v %v", evicted, k, v)
+	}
+	// Re-admitting the ghost 1 evicts from the frequent queue
+	if evicted, k, v := l.AddWithEviction(1, "d"); !evicted || k != 2 || v != "b" {
+		t.Fatalf("bad: %v %v %v", evicted, k, v)
+	}
+}
+
+// Test that the queue lengths and ghost hits are reported
+func Test2Q_QueueLens(t *testing.T) {
+	l, err := New2QParams(4, 0.5, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		l.Add(i, i)
+	}
+	l.Get(2)
+	if r, f, g := l.RecentLen(), l.FrequentLen(), l.GhostLen(); r != 3 || f != 1 || g != 1 {
+		t.Fatalf("bad lens: %v %v %v", r, f, g)
+	}
+	if n := l.GhostHits(); n != 0 {
+		t.Fatalf("bad ghost hits: %v", n)
+	}
+
+	// 1 is promoted from the ghost list, evicting 3 into it
+	l.Add(1, 1)
+	if r, f, g := l.RecentLen(), l.FrequentLen(), l.GhostLen(); r != 2 || f != 2 || g != 1 {
+		t.Fatalf("bad lens: %v %v %v", r, f, g)
+	}
+	if n := l.GhostHits(); n != 1 {
+		t.Fatalf("bad ghost hits: %v", n)
+	}
+	l.ResetStats()
+	if n := l.GhostHits(); n != 0 {
+		t.Fatalf("bad ghost hits: %v", n)
+	}
+}
+
+// Test that the ratios of a live cache can be changed
+func Test2Q_SetRatios(t *testing.T) {
+	var evicted []interface{}
+	l, err := New2QParamsWithEvict(8, 0.25, 0.5, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		l.Add(i, i)
+	}
+	if g := l.GhostLen(); g != 4 {
+		t.Fatalf("bad ghost len: %v", g)
+	}
+
+	// The recent queue is rebalanced down to its new target right away
+	evicted = nil
+	expired := 0
+	l.OnGhostExpire(func(k interface{}) { expired++ })
+	if err := l.SetRatios(0.5, 0.25); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r := l.RecentRatio(); r != 0.5 {
+		t.Fatalf("bad ratio: %v", r)
+	}
+	if r, n := l.RecentLen(), l.Len(); r != 4 || n != 4 {
+		t.Fatalf("bad lens: %v %v", r, n)
+	}
+	if len(evicted) != 4 || evicted[0] != 8 || evicted[3] != 11 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if g := l.GhostLen(); g != 2 || expired != 4 {
+		t.Fatalf("bad ghosts: %v %v", g, expired)
+	}
+	l.OnGhostExpire(nil)
+
+	// Promote 4 entries, the recent queue now keeps 4
+	for i := 12; i < 16; i++ {
+		l.Get(i)
+	}
+	for i := 16; i < 24; i++ {
+		l.Add(i, i)
+	}
+	if r, f := l.RecentLen(), l.FrequentLen(); r != 4 || f != 4 {
+		t.Fatalf("bad lens: %v %v", r, f)
+	}
+
+	if err := l.SetRatios(1.5, 0.5); err == nil {
+		t.Fatalf("should reject recent ratio")
+	}
+	if err := l.SetRatios(0.5, 0.1); err == nil {
+		t.Fatalf("should reject empty ghost list")
+	}
+}
+
+// Test that a ghost hit evicts from the recent queue when the frequent
+// queue is empty
+func Test2Q_SetRatios_AllRecent(t *testing.T) {
+	var evicted []interface{}
+	l, err := New2QParamsWithEvict(4, 0.25, 0.5, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.SetRatios(1.0, 0.5); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	l.Add(0, 0)
+	if l.Len() != 4 || l.FrequentLen() != 1 {
+		t.Fatalf("bad lens: %v %v", l.Len(), l.FrequentLen())
+	}
+	if len(evicted) != 3 || evicted[0] != 0 || evicted[1] != 1 || evicted[2] != 2 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if st := l.Stats(); st.Evictions != 3 {
+		t.Fatalf("bad evictions: %v", st.Evictions)
+	}
+}
+
+// Test that Range visits the entries in the order of Keys
+func Test2Q_Range(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(1)
+
+	var keys []interface{}
+	l.Range(func(k, v interface{}) bool {
+		if v != k.(int)*10 {
+			t.Fatalf("bad value: %v %v", k, v)
+		}
+		keys = append(keys, k)
+		return true
+	})
+	if !equalKeys(keys, l.Keys()) || keys[0] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	n := 0
+	l.Range(func(k, v interface{}) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Fatalf("should stop early: %v", n)
+	}
+	if l.FrequentLen() != 1 {
+		t.Fatalf("Range should not promote")
+	}
+}
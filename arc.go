@@ -24,17 +24,19 @@ type ARCCache struct {
 	t2 simplelru.LRUCache // T2 is the LRU for frequently accessed items
 	b2 simplelru.LRUCache // B2 is the LRU for evictions from t2
 
-	lock sync.RWMutex
+	stats   stats
+	flights flightGroup
+	lock    sync.RWMutex
 }
 
 // NewARC creates an ARC of the given size
 func NewARC(size int) (*ARCCache, error) {
 	// Create the sub LRUs
-	b1, err := simplelru.NewLRU(size, nil)
+	b1, err := simplelru.NewGhost(size)
 	if err != nil {
 		return nil, err
 	}
-	b2, err := simplelru.NewLRU(size, nil)
+	b2, err := simplelru.NewGhost(size)
 	if err != nil {
 		return nil, err
 	}
@@ -63,21 +65,29 @@ func NewARC(size int) (*ARCCache, error) {
 func (c *ARCCache) Get(key interface{}) (value interface{}, ok bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return c.get(key)
+}
 
+// get looks up a key's value, promoting it to the frequent list.
+// It must be called with the lock held.
+func (c *ARCCache) get(key interface{}) (value interface{}, ok bool) {
 	// If the value is contained in T1 (recent), then
 	// promote it to T2 (frequent)
 	if val, ok := c.t1.Peek(key); ok {
 		c.t1.Remove(key)
 		c.t2.Add(key, val)
+		c.stats.hits++
 		return val, ok
 	}
 
 	// Check if the value is contained in T2 (frequent)
 	if val, ok := c.t2.Get(key); ok {
+		c.stats.hits++
 		return val, ok
 	}
 
 	// No hit
+	c.stats.misses++
 	return nil, false
 }
 
@@ -85,19 +95,58 @@ func (c *ARCCache) Get(key interface{}) (value interface{}, ok bool) {
 func (c *ARCCache) Add(key, value interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.add(key, value)
+}
+
+// GetOrAdd looks up a key's value from the cache, promoting it like Get,
+// and adds the provided value if it is not contained. A key in one of the
+// ghost lists adapts the cache as with Add. Returns the value now cached
+// for the key and whether it was already contained.
+func (c *ARCCache) GetOrAdd(key, value interface{}) (actual interface{}, loaded bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if actual, ok := c.get(key); ok {
+		return actual, true
+	}
+	c.add(key, value)
+	return value, false
+}
 
+// GetOrCompute looks up a key's value from the cache, promoting it like
+// Get, and otherwise computes the value with fn and adds it as with Add.
+// Nothing is added if fn returns an error, which is returned as is. fn runs
+// outside of the lock, and concurrent lookups of the same missing key share
+// a single call of fn, the others waiting for its result, so fn may call
+// into the cache for other keys but not for the key itself.
+func (c *ARCCache) GetOrCompute(key interface{}, fn func(key interface{}) (interface{}, error)) (value interface{}, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	return c.flights.do(key, func() (interface{}, error) {
+		value, err := fn(key)
+		if err == nil {
+			c.Add(key, value)
+		}
+		return value, err
+	})
+}
+
+// add adds a value to the cache, returning true if an eviction occurred.
+// It must be called with the lock held.
+func (c *ARCCache) add(key, value interface{}) (evicted bool) {
+	c.stats.adds++
 	// Check if the value is contained in T1 (recent), and potentially
 	// promote it to frequent T2
 	if c.t1.Contains(key) {
 		c.t1.Remove(key)
 		c.t2.Add(key, value)
-		return
+		return false
 	}
 
 	// Check if the value is already in T2 (frequent) and update it
 	if c.t2.Contains(key) {
 		c.t2.Add(key, value)
-		return
+		return false
 	}
 
 	// Check if this value was recently evicted as part of the
@@ -118,7 +167,7 @@ func (c *ARCCache) Add(key, value interface{}) {
 
 		// Potentially need to make room in the cache
 		if c.t1.Len()+c.t2.Len() >= c.size {
-			c.replace(false)
+			evicted = c.replace(false)
 		}
 
 		// Remove from B1
@@ -126,7 +175,7 @@ func (c *ARCCache) Add(key, value interface{}) {
 
 		// Add the key to the frequently used list
 		c.t2.Add(key, value)
-		return
+		return evicted
 	}
 
 	// Check if this value was recently evicted as part of the
@@ -147,7 +196,7 @@ func (c *ARCCache) Add(key, value interface{}) {
 
 		// Potentially need to make room in the cache
 		if c.t1.Len()+c.t2.Len() >= c.size {
-			c.replace(true)
+			evicted = c.replace(true)
 		}
 
 		// Remove from B2
@@ -155,12 +204,12 @@ func (c *ARCCache) Add(key, value interface{}) {
 
 		// Add the key to the frequently used list
 		c.t2.Add(key, value)
-		return
+		return evicted
 	}
 
 	// Potentially need to make room in the cache
 	if c.t1.Len()+c.t2.Len() >= c.size {
-		c.replace(false)
+		evicted = c.replace(false)
 	}
 
 	// Keep the size of the ghost buffers trim
@@ -173,23 +222,45 @@ func (c *ARCCache) Add(key, value interface{}) {
 
 	// Add to the recently seen list
 	c.t1.Add(key, value)
+	return evicted
 }
 
 // replace is used to adaptively evict from either T1 or T2
-// based on the current learned value of P
-func (c *ARCCache) replace(b2ContainsKey bool) {
+// based on the current learned value of P. Returns true if an
+// entry was evicted.
+func (c *ARCCache) replace(b2ContainsKey bool) (evicted bool) {
 	t1Len := c.t1.Len()
 	if t1Len > 0 && (t1Len > c.p || (t1Len == c.p && b2ContainsKey)) {
 		k, _, ok := c.t1.RemoveOldest()
 		if ok {
+			c.stats.evictions++
 			c.b1.Add(k, nil)
 		}
-	} else {
-		k, _, ok := c.t2.RemoveOldest()
-		if ok {
-			c.b2.Add(k, nil)
-		}
+		return ok
+	}
+	k, _, ok := c.t2.RemoveOldest()
+	if ok {
+		c.stats.evictions++
+		c.b2.Add(k, nil)
 	}
+	return ok
+}
+
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity. Get,
+// GetOrAdd and GetOrCompute count as lookups, and only removals made to
+// free capacity count as evictions.
+func (c *ARCCache) Stats() simplelru.Stats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stats.snapshot(c.t1.Len()+c.t2.Len(), c.size)
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *ARCCache) ResetStats() {
+	c.lock.Lock()
+	c.stats = stats{}
+	c.lock.Unlock()
 }
 
 // Len returns the number of cached entries
@@ -208,22 +279,61 @@ func (c *ARCCache) Keys() []interface{} {
 	return append(k1, k2...)
 }
 
-// Remove is used to purge a key from the cache
+// Range calls fn for each entry in the cache, in the order of Keys, without
+// promoting the keys. It stops early if fn returns false. The read lock is
+// held throughout, so fn must not call back into the cache.
+func (c *ARCCache) Range(fn func(key, value interface{}) bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if rangeLRU(c.t1, fn) {
+		rangeLRU(c.t2, fn)
+	}
+}
+
+// Remove is used to purge a key from the cache, or from the ghost lists.
 func (c *ARCCache) Remove(key interface{}) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.remove(key)
+}
+
+// remove purges a key from the cache, or from the ghost lists, returning
+// if it was contained in the cache. It must be called with the lock held.
+func (c *ARCCache) remove(key interface{}) (present bool) {
 	if c.t1.Remove(key) {
-		return
+		return true
 	}
 	if c.t2.Remove(key) {
-		return
+		return true
+	}
+	if !c.b1.Remove(key) {
+		c.b2.Remove(key)
 	}
-	if c.b1.Remove(key) {
-		return
+	return false
+}
+
+// Resize changes the cache size and returns the number of entries
+// evicted, which are tracked by the ghost lists as usual. The ghost lists
+// are trimmed to the new size, and sizes below 1 are raised to 1.
+func (c *ARCCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
 	}
-	if c.b2.Remove(key) {
-		return
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = size
+	if c.p > size {
+		c.p = size
+	}
+	for c.t1.Len()+c.t2.Len() > size {
+		c.replace(false)
+		evicted++
 	}
+	c.t1.Resize(size)
+	c.t2.Resize(size)
+	c.b1.Resize(size)
+	c.b2.Resize(size)
+	return evicted
 }
 
 // Purge is used to clear the cache
@@ -4,6 +4,8 @@ import (
 	"math/rand"
 	"testing"
 	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 func init() {
@@ -375,3 +377,92 @@ func TestARC_Peek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// Test that GetOrAdd only adds missing keys
+func TestARC_GetOrAdd(t *testing.T) {
+	l, err := NewARC(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, loaded := l.GetOrAdd(1, 1); loaded || v != 1 {
+		t.Fatalf("bad: %v %v", v, loaded)
+	}
+	if v, loaded := l.GetOrAdd(1, 2); !loaded || v != 1 {
+		t.Fatalf("bad: %v %v", v, loaded)
+	}
+	if !l.t2.Contains(1) {
+		t.Fatalf("a hit should be promoted")
+	}
+}
+
+// Test that GetOrCompute only computes missing keys
+func TestARC_GetOrCompute(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testGetOrCompute(t, l.GetOrCompute)
+}
+
+// Test that Stats counts lookups, adds and evictions
+func TestARC_Stats(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 6; i++ {
+		l.Get(i)
+	}
+	l.Remove(5)
+
+	st := l.Stats()
+	expected := simplelru.Stats{Hits: 4, Misses: 2, Adds: 6, Evictions: 2, Len: 3, Cap: 4, HitRatio: 4.0 / 6.0}
+	if st != expected {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	l.ResetStats()
+	if st := l.Stats(); st != (simplelru.Stats{Len: 3, Cap: 4}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+// Test that Range visits the entries in the order of Keys
+func TestARC_Range(t *testing.T) {
+	l, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(1)
+
+	var keys []interface{}
+	l.Range(func(k, v interface{}) bool {
+		if v != k.(int)*10 {
+			t.Fatalf("bad value: %v %v", k, v)
+		}
+		keys = append(keys, k)
+		return true
+	})
+	if !equalKeys(keys, l.Keys()) || keys[3] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	n := 0
+	l.Range(func(k, v interface{}) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("should stop early: %v", n)
+	}
+	if l.t2.Len() != 1 {
+		t.Fatalf("Range should not promote")
+	}
+}
@@ -0,0 +1,208 @@
+package lru
+
+import "sync"
+
+// evictedEntry is an entry removed from the cache whose callbacks
+// are still to be invoked.
+type evictedEntry struct {
+	key, value interface{}
+	onEvict    func(value interface{})
+}
+
+// pendingCallbacks holds the callbacks produced by an operation, to be
+// invoked outside of the critical section. A single eviction is held
+// inline so that the common case does not allocate.
+type pendingCallbacks struct {
+	single       evictedEntry
+	hasSingle    bool
+	evicted      []evictedEntry
+	transition   bool
+	watermark    func(len int)
+	watermarkLen int
+	safe         bool
+	onPanic      func(recovered interface{})
+}
+
+// lenWatermark is an edge-triggered notification of the cache length
+// reaching a threshold.
+type lenWatermark struct {
+	high  int
+	fn    func(len int)
+	armed bool
+}
+
+// transitionQueue delivers the callbacks of the changes between empty and
+// non-empty in the order the changes happened, whichever goroutines made
+// them, so that an observer never ends up notified of a state older than
+// the current one.
+type transitionQueue struct {
+	lock     sync.Mutex
+	pending  []func()
+	draining bool
+}
+
+// push queues a callback. It must be called with the lock of the cache
+// held, so that callbacks are queued in the order of the changes.
+func (q *transitionQueue) push(fn func()) {
+	q.lock.Lock()
+	q.pending = append(q.pending, fn)
+	q.lock.Unlock()
+}
+
+// drain invokes the queued callbacks in order using call, unless another
+// goroutine is already doing so, in which case that goroutine invokes them
+// too. A callback making a change of its own thus queues its callback
+// instead of deadlocking. It must be called without the lock of the cache.
+func (q *transitionQueue) drain(call func(fn func())) {
+	q.lock.Lock()
+	if q.draining {
+		q.lock.Unlock()
+		return
+	}
+	q.draining = true
+	for len(q.pending) > 0 {
+		fn := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.lock.Unlock()
+		q.run(fn, call)
+		q.lock.Lock()
+	}
+	q.pending = nil
+	q.draining = false
+	q.lock.Unlock()
+}
+
+// run invokes a callback, handing the queue over to the next drain if it
+// panics.
+func (q *transitionQueue) run(fn func(), call func(fn func())) {
+	done := false
+	defer func() {
+		if !done {
+			q.lock.Lock()
+			q.draining = false
+			q.lock.Unlock()
+		}
+	}()
+	call(fn)
+	done = true
+}
+
+// endRemove ends a removal that runs caller code under the lock: it clears
+// the removing flag, releases the lock and delivers the callbacks produced
+// since the length of the cache was before. Deferring it keeps a panicking
+// match function from leaving the cache locked or dropping the callbacks.
+func (c *Cache) endRemove(before int) {
+	c.removing = false
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+}
+
+// takeCallbacks collects the callbacks produced since the length of the
+// cache was before, resetting the eviction buffer. It must be called with
+// the lock held.
+func (c *Cache) takeCallbacks(before int) (cb pendingCallbacks) {
+	cb.safe, cb.onPanic = c.safeCallbacks, c.onPanic
+	if fn := c.lenTransition(before); fn != nil {
+		c.transitions.push(fn)
+		cb.transition = true
+	}
+	if w := c.watermark; w != nil {
+		if n := c.lru.Len(); n < w.high {
+			w.armed = true
+		} else if w.armed {
+			w.armed = false
+			cb.watermark, cb.watermarkLen = w.fn, n
+		}
+	}
+	switch len(c.evicted) {
+	case 0:
+	case 1:
+		cb.single, cb.hasSingle = c.evicted[0], true
+		c.evicted = c.evicted[:0]
+	default:
+		cb.evicted = c.evicted
+		c.initEvictBuffers()
+	}
+	return cb
+}
+
+// invoke runs the pending callbacks. It must be called without the lock.
+func (cb *pendingCallbacks) invoke(c *Cache) {
+	if cb.hasSingle {
+		cb.fireEvicted(c, cb.single)
+	}
+	for _, e := range cb.evicted {
+		cb.fireEvicted(c, e)
+	}
+	if cb.transition {
+		c.transitions.drain(cb.call)
+	}
+	if cb.watermark != nil {
+		cb.callLen(cb.watermark, cb.watermarkLen)
+	}
+}
+
+// fireEvicted invokes the eviction callbacks registered for an entry.
+func (cb *pendingCallbacks) fireEvicted(c *Cache, e evictedEntry) {
+	if c.onEvictedCB != nil {
+		cb.callKV(c.onEvictedCB, e.key, e.value)
+	}
+	if e.onEvict != nil {
+		cb.callV(e.onEvict, e.value)
+	}
+}
+
+// call invokes fn, recovering from panics if safe callbacks are enabled.
+func (cb *pendingCallbacks) call(fn func()) {
+	if cb.safe {
+		defer cb.recover()
+	}
+	fn()
+}
+
+// callLen invokes fn, recovering from panics if safe callbacks are enabled.
+func (cb *pendingCallbacks) callLen(fn func(len int), n int) {
+	if cb.safe {
+		defer cb.recover()
+	}
+	fn(n)
+}
+
+// callKV invokes fn, recovering from panics if safe callbacks are enabled.
+func (cb *pendingCallbacks) callKV(fn func(k, v interface{}), k, v interface{}) {
+	if cb.safe {
+		defer cb.recover()
+	}
+	fn(k, v)
+}
+
+// callV invokes fn, recovering from panics if safe callbacks are enabled.
+func (cb *pendingCallbacks) callV(fn func(v interface{}), v interface{}) {
+	if cb.safe {
+		defer cb.recover()
+	}
+	fn(v)
+}
+
+// recover stops a panicking callback and forwards it to the panic handler.
+func (cb *pendingCallbacks) recover() {
+	if r := recover(); r != nil && cb.onPanic != nil {
+		cb.onPanic(r)
+	}
+}
+
+// EnableSafeCallbacks protects the cache from panics in the callbacks it
+// invokes: eviction callbacks, OnEvictKey handlers, emptiness callbacks and
+// the length watermark. Each callback is recovered individually, so a
+// panicking callback does not prevent the others from running, and the
+// recovered value is passed to onPanic if it is not nil. Callbacks always
+// run outside of the critical section, so the cache itself stays consistent
+// and unlocked either way; without safe callbacks the panic propagates to
+// the caller of the operation and the remaining callbacks are skipped.
+func (c *Cache) EnableSafeCallbacks(onPanic func(recovered interface{})) {
+	c.lock.Lock()
+	c.safeCallbacks, c.onPanic = true, onPanic
+	c.lock.Unlock()
+}
@@ -0,0 +1,204 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ClockCache is a thread-safe fixed size CLOCK cache, also known as second
+// chance. Entries sit in a ring swept by a hand: an entry looked up since
+// the hand last passed it has its reference bit set and is spared once,
+// clearing the bit, while an entry without it is evicted. The hit ratio is
+// slightly worse than LRU, but Get only sets a bit instead of reordering a
+// list, under a shared lock, which suits read-heavy concurrent use.
+type ClockCache struct {
+	size  int
+	items map[interface{}]int
+	slots []*clockEntry
+	free  []int
+	hand  int
+	lock  sync.RWMutex
+}
+
+// clockEntry is an entry of a ClockCache.
+type clockEntry struct {
+	key, value interface{}
+	ref        uint32 // accessed atomically
+}
+
+// NewClock creates a ClockCache of the given size.
+func NewClock(size int) (*ClockCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	c := &ClockCache{
+		size:  size,
+		items: make(map[interface{}]int),
+	}
+	return c, nil
+}
+
+// Get looks up a key's value from the cache, setting its reference bit.
+func (c *ClockCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	i, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := c.slots[i]
+	if atomic.LoadUint32(&e.ref) == 0 {
+		atomic.StoreUint32(&e.ref, 1)
+	}
+	return e.value, true
+}
+
+// Add adds a value to the cache, setting the reference bit of an existing
+// key. Returns true if an eviction occurred.
+func (c *ClockCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if i, ok := c.items[key]; ok {
+		e := c.slots[i]
+		e.value = value
+		atomic.StoreUint32(&e.ref, 1)
+		return false
+	}
+
+	e := &clockEntry{key: key, value: value}
+	switch {
+	case len(c.free) > 0:
+		i := c.free[len(c.free)-1]
+		c.free = c.free[:len(c.free)-1]
+		c.slots[i] = e
+		c.items[key] = i
+	case len(c.slots) < c.size:
+		c.items[key] = len(c.slots)
+		c.slots = append(c.slots, e)
+	default:
+		delete(c.items, c.sweep().key)
+		c.slots[c.hand] = e
+		c.items[key] = c.hand
+		c.advance()
+		evicted = true
+	}
+	return evicted
+}
+
+// sweep moves the hand to the next entry without reference bit, clearing
+// the bits on the way, and returns it. The cache must not be empty.
+func (c *ClockCache) sweep() *clockEntry {
+	for {
+		if e := c.slots[c.hand]; e != nil {
+			if atomic.LoadUint32(&e.ref) == 0 {
+				return e
+			}
+			atomic.StoreUint32(&e.ref, 0)
+		}
+		c.advance()
+	}
+}
+
+// advance moves the hand to the next slot.
+func (c *ClockCache) advance() {
+	c.hand++
+	if c.hand == len(c.slots) {
+		c.hand = 0
+	}
+}
+
+// Peek returns the key value (or undefined if not found) without setting
+// its reference bit.
+func (c *ClockCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if i, ok := c.items[key]; ok {
+		return c.slots[i].value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without setting its reference
+// bit.
+func (c *ClockCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *ClockCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	i, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	delete(c.items, key)
+	c.slots[i] = nil
+	c.free = append(c.free, i)
+	return true
+}
+
+// Keys returns a slice of the keys in the cache, in the order the hand
+// sweeps them, starting from the next one it considers for eviction.
+func (c *ClockCache) Keys() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := make([]interface{}, 0, len(c.items))
+	for n := 0; n < len(c.slots); n++ {
+		if e := c.slots[(c.hand+n)%len(c.slots)]; e != nil {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *ClockCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.items)
+}
+
+// Resize changes the cache size, returning the number of entries evicted.
+// Sizes below 1 are raised to 1.
+func (c *ClockCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = size
+	for len(c.items) > size {
+		delete(c.items, c.sweep().key)
+		c.slots[c.hand] = nil
+		c.advance()
+		evicted++
+	}
+	if len(c.slots) > size {
+		// Compact the ring from the hand, dropping the free slots
+		slots := make([]*clockEntry, 0, size)
+		for n := 0; n < len(c.slots); n++ {
+			if e := c.slots[(c.hand+n)%len(c.slots)]; e != nil {
+				c.items[e.key] = len(slots)
+				slots = append(slots, e)
+			}
+		}
+		c.slots, c.free, c.hand = slots, nil, 0
+	}
+	return evicted
+}
+
+// Purge is used to completely clear the cache.
+func (c *ClockCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[interface{}]int)
+	c.slots = nil
+	c.free = nil
+	c.hand = 0
+}
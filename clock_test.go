@@ -0,0 +1,110 @@
+package lru
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func BenchmarkClock_Rand(b *testing.B) {
+	l, err := NewClock(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestClock(t *testing.T) {
+	l, err := NewClock(3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("should not evict")
+		}
+	}
+
+	// 0 gets a second chance, 1 is evicted
+	l.Get(0)
+	if !l.Add(3, 3) {
+		t.Fatalf("should evict")
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{2, 0, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// Peek and Contains do not set the reference bit
+	if v, ok := l.Peek(2); !ok || v != 2 || !l.Contains(2) {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	l.Add(4, 4)
+	if l.Contains(2) {
+		t.Fatalf("should evict 2: %v", l.Keys())
+	}
+
+	// Removed slots are reused before evicting
+	if !l.Remove(0) || l.Remove(0) || l.Len() != 2 {
+		t.Fatalf("bad remove")
+	}
+	if l.Add(5, 5) {
+		t.Fatalf("should not evict")
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{5, 3, 4}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	l.Purge()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad purge")
+	}
+	if _, err := NewClock(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
+
+// Test that concurrent lookups and adds are safe
+func TestClock_Concurrent(t *testing.T) {
+	l, err := NewClock(64)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if g == 0 {
+					l.Add(i%128, i)
+				} else {
+					l.Get(i % 128)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if l.Len() != 64 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
@@ -1,4 +1,5 @@
-// Package lru provides three different LRU caches of varying sophistication.
+// Package lru provides three different LRU caches of varying sophistication,
+// along with caches using other eviction policies.
 //
 // Cache is a simple LRU cache. It is based on the
 // LRU implementation in groupcache:
@@ -16,6 +17,16 @@
 // ARC has been patented by IBM, so do not use it if that is problematic for
 // your program.
 //
+// LFUCache, SLRUCache, TinyLFUCache, ClockCache, SieveCache, S3FIFOCache and
+// LRUKCache implement other well known policies. Every cache implements
+// Interface, so that the policy can be chosen without code changes.
+//
+// Keys are compared as interface values, so keys of distinct types never
+// collide even when their underlying values are equal. Declaring named key
+// types, such as type UserID int64 and type OrderID int64, keeps caches
+// shared between kinds of identifiers safe from mixups: UserID(1) and
+// OrderID(1) are different keys.
+//
 // All caches in this package take locks while operating, and are therefore
 // thread-safe for consumers.
 package lru
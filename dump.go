@@ -0,0 +1,74 @@
+package lru
+
+import (
+	"fmt"
+	"io"
+)
+
+// DumpKeys is the number of keys written by Dump from each end of a
+// list of keys.
+const DumpKeys = 5
+
+// Dump writes a human-readable report of the cache state to w: capacity,
+// length, statistics and the oldest and newest keys. The report is meant
+// to be attached to bug reports, its format is not stable. It does not
+// update the "recently used"-ness of any key, and the lock is not held
+// while writing to w.
+func (c *Cache) Dump(w io.Writer) error {
+	c.lock.RLock()
+	st := c.lru.Stats()
+	keys := c.lru.Keys()
+	c.lock.RUnlock()
+
+	d := dumper{w: w}
+	d.printf("capacity: %d\n", st.Cap)
+	d.printf("length: %d\n", st.Len)
+	d.printf("hits: %d, misses: %d, evictions: %d, hit ratio: %.3f\n",
+		st.Hits, st.Misses, st.Evictions, st.HitRatio)
+	d.keys("keys", keys)
+	return d.err
+}
+
+// Dump writes a human-readable report of the cache state to w: capacity,
+// the recent, frequent and ghost queue lengths, and the oldest and newest
+// keys of each queue. The report is meant to be attached to bug reports,
+// its format is not stable. It does not update the "recently used"-ness of
+// any key, and the lock is not held while writing to w.
+func (c *TwoQueueCache) Dump(w io.Writer) error {
+	c.lock.RLock()
+	size, recentSize := c.size, c.recentSize
+	recent, frequent, ghost := c.recent.Keys(), c.frequent.Keys(), c.recentEvict.Keys()
+	c.lock.RUnlock()
+
+	d := dumper{w: w}
+	d.printf("capacity: %d (recent target %d)\n", size, recentSize)
+	d.printf("length: %d (recent %d, frequent %d, ghost %d)\n",
+		len(recent)+len(frequent), len(recent), len(frequent), len(ghost))
+	d.keys("recent", recent)
+	d.keys("frequent", frequent)
+	d.keys("ghost", ghost)
+	return d.err
+}
+
+// dumper writes a report, remembering the first write error.
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// keys writes keys, ordered from oldest to newest, abbreviating to
+// DumpKeys keys from each end when there are more.
+func (d *dumper) keys(name string, keys []interface{}) {
+	if len(keys) <= 2*DumpKeys {
+		d.printf("%s: %v\n", name, keys)
+		return
+	}
+	d.printf("%s oldest: %v\n", name, keys[:DumpKeys])
+	d.printf("%s newest: %v\n", name, keys[len(keys)-DumpKeys:])
+}
@@ -0,0 +1,65 @@
+package lru
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCacheDump(t *testing.T) {
+	l, err := New(20)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 12; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+	l.Get(100)
+
+	var buf bytes.Buffer
+	if err := l.Dump(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := "capacity: 20\n" +
+		"length: 12\n" +
+		"hits: 1, misses: 1, evictions: 0, hit ratio: 0.500\n" +
+		"keys oldest: [1 2 3 4 5]\n" +
+		"keys newest: [8 9 10 11 0]\n"
+	if buf.String() != want {
+		t.Fatalf("bad dump:\n%s", buf.String())
+	}
+
+	// Dumping does not update recent-ness
+	l.Dump(&bytes.Buffer{})
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+}
+
+func TestTwoQueueCacheDump(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		l.Add(i, i)
+	}
+	l.Get(4)
+
+	var buf bytes.Buffer
+	if err := l.Dump(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"capacity: 4 (recent target 1)\n",
+		"length: 4 (recent 3, frequent 1, ghost 1)\n",
+		"frequent: [4]\n",
+		"ghost: [0]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in dump:\n%s", want, out)
+		}
+	}
+}
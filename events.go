@@ -0,0 +1,84 @@
+package lru
+
+const (
+	// DefaultEventBufferSize defines the buffer size of each channel
+	// returned by Subscribe
+	DefaultEventBufferSize = 256
+)
+
+// EventType identifies the kind of mutation an Event describes.
+type EventType int
+
+const (
+	// EventAdd is published when a value is added or updated.
+	EventAdd EventType = iota
+
+	// EventRemove is published when an entry is explicitly removed,
+	// including by RemoveOldest, DrainOldest and purges.
+	EventRemove
+
+	// EventEvict is published when an entry is evicted to make room,
+	// either by an add or by shrinking the cache.
+	EventEvict
+)
+
+// Event describes a single mutation of a Cache.
+type Event struct {
+	Type  EventType
+	Key   interface{}
+	Value interface{}
+}
+
+// subscriber is a registered receiver of cache events.
+type subscriber struct {
+	events chan Event
+}
+
+// Subscribe returns a channel receiving an Event for every mutation of the
+// cache, along with a function that unsubscribes and closes the channel.
+//
+// Events are published while the mutation holds the lock, so every
+// subscriber observes them in the order the mutations were applied. An add
+// that causes an eviction publishes the eviction first. Publishing never
+// blocks: once a subscriber's buffer of DefaultEventBufferSize events is
+// full, further events for it are dropped and counted by DroppedEvents.
+func (c *Cache) Subscribe() (<-chan Event, func()) {
+	s := &subscriber{events: make(chan Event, DefaultEventBufferSize)}
+	c.lock.Lock()
+	c.subscribers = append(c.subscribers, s)
+	c.lock.Unlock()
+
+	unsubscribe := func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for i, sub := range c.subscribers {
+			if sub == s {
+				c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+				close(s.events)
+				return
+			}
+		}
+	}
+	return s.events, unsubscribe
+}
+
+// DroppedEvents returns the number of events dropped because a
+// subscriber's buffer was full.
+func (c *Cache) DroppedEvents() uint64 {
+	c.lock.RLock()
+	dropped := c.droppedEvents
+	c.lock.RUnlock()
+	return dropped
+}
+
+// publish sends an event to every subscriber without blocking.
+// It must be called with the lock held.
+func (c *Cache) publish(t EventType, key, value interface{}) {
+	for _, s := range c.subscribers {
+		select {
+		case s.events <- Event{t, key, value}:
+		default:
+			c.droppedEvents++
+		}
+	}
+}
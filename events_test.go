@@ -0,0 +1,58 @@
+package lru
+
+import "testing"
+
+func TestCacheSubscribe(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	events, unsubscribe := l.Subscribe()
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Remove(2)
+	l.Get(3)
+	l.Purge()
+
+	expected := []Event{
+		{EventAdd, 1, 1},
+		{EventAdd, 2, 2},
+		{EventEvict, 1, 1},
+		{EventAdd, 3, 3},
+		{EventRemove, 2, 2},
+		{EventRemove, 3, 3},
+	}
+	for _, want := range expected {
+		if got := <-events; got != want {
+			t.Fatalf("bad event: %v, expected %v", got, want)
+		}
+	}
+
+	unsubscribe()
+	unsubscribe()
+	l.Add(4, 4)
+	if _, ok := <-events; ok {
+		t.Fatalf("channel should be closed")
+	}
+}
+
+func TestCacheSubscribe_Dropped(t *testing.T) {
+	l, err := New(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	events, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < DefaultEventBufferSize+10; i++ {
+		l.Add(0, i)
+	}
+	if dropped := l.DroppedEvents(); dropped != 10 {
+		t.Fatalf("bad dropped count: %v", dropped)
+	}
+	if e := <-events; e.Value != 0 {
+		t.Fatalf("oldest event should have been kept: %v", e)
+	}
+}
@@ -0,0 +1,390 @@
+// Package expirable provides a thread-safe LRU cache whose entries expire
+// after a time to live.
+package expirable
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// LRU is a thread-safe fixed size LRU cache whose entries expire after a
+// time to live. Expired entries are removed lazily, when looked up, by
+// calling DeleteExpired or by a janitor goroutine started WithJanitor.
+// Until then they still take up capacity and are counted by Len, but are
+// never returned.
+type LRU struct {
+	lru         *simplelru.LRU
+	ttl         time.Duration
+	afterAccess bool
+	onEvict     simplelru.EvictCallback
+	evicted     []simplelru.Entry
+	clock       Clock
+	epoch       uint64
+	lock        sync.Mutex
+
+	janitor   time.Duration
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// Option configures an LRU on creation.
+type Option func(c *LRU)
+
+// Clock tells the current time to an LRU.
+type Clock interface {
+	Now() time.Time
+}
+
+// TickerClock is a Clock that also drives the janitor started WithJanitor,
+// so that tests can run janitor passes without sleeping. NewTicker returns
+// a channel delivering a tick every interval and a function stopping it.
+type TickerClock interface {
+	Clock
+	NewTicker(interval time.Duration) (ticks <-chan time.Time, stop func())
+}
+
+// systemClock is the Clock reading the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// WithClock makes the LRU tell time using clock instead of the system
+// clock, so that tests can advance time without sleeping. If clock is a
+// TickerClock, it also drives the janitor; otherwise the janitor runs on
+// a system ticker.
+func WithClock(clock Clock) Option {
+	return func(c *LRU) {
+		c.clock = clock
+	}
+}
+
+// WithExpireAfterAccess makes entries expire after their TTL has elapsed
+// since they were last added or looked up by Get, instead of since they
+// were last added. Entries then only expire once idle. Peek, Contains and
+// the other methods not updating the "recently used"-ness of a key do not
+// refresh its expiration.
+func WithExpireAfterAccess() Option {
+	return func(c *LRU) {
+		c.afterAccess = true
+	}
+}
+
+// WithJanitor starts a goroutine removing the expired entries every
+// interval, so that entries never looked up again do not hold on to memory
+// until evicted. Close stops the goroutine. An interval of zero or less
+// starts no goroutine.
+func WithJanitor(interval time.Duration) Option {
+	return func(c *LRU) {
+		c.janitor = interval
+	}
+}
+
+// item is the value stored in the underlying LRU.
+type item struct {
+	value   interface{}
+	ttl     time.Duration
+	expires time.Time
+	epoch   uint64
+}
+
+// touch sets the expiration time of the item from its TTL and now.
+func (it *item) touch(now time.Time) {
+	it.expires = time.Time{}
+	if it.ttl > 0 {
+		it.expires = now.Add(it.ttl)
+	}
+}
+
+// expired reports whether the item has expired at now. Items without an
+// expiration time never expire.
+func (it *item) expired(now time.Time) bool {
+	return !it.expires.IsZero() && !now.Before(it.expires)
+}
+
+// NewLRU creates an LRU of the given size whose entries expire after ttl,
+// unless added with another TTL by AddWithTTL. A ttl of zero or less means
+// that entries do not expire by default. The eviction callback is invoked,
+// outside of the lock, for entries evicted, removed or expired.
+func NewLRU(size int, onEvict simplelru.EvictCallback, ttl time.Duration, opts ...Option) (*LRU, error) {
+	c := &LRU{
+		ttl:     ttl,
+		onEvict: onEvict,
+		clock:   systemClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	lru, err := simplelru.NewLRU(size, c.onEvicted)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	if c.janitor > 0 {
+		c.done = make(chan struct{})
+		c.stopped = make(chan struct{})
+		go c.runJanitor()
+	}
+	return c, nil
+}
+
+// runJanitor removes the expired entries every janitor interval until the
+// cache is closed.
+func (c *LRU) runJanitor() {
+	clock, ok := c.clock.(TickerClock)
+	if !ok {
+		clock = systemClock{}
+	}
+	ticks, stop := clock.NewTicker(c.janitor)
+	defer close(c.stopped)
+	defer stop()
+	for {
+		select {
+		case <-ticks:
+			c.DeleteExpired()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close stops the janitor goroutine started WithJanitor, if any, waiting
+// for it to exit. The cache remains usable, expired entries being removed
+// lazily only. It is safe to call Close several times.
+func (c *LRU) Close() {
+	c.closeOnce.Do(func() {
+		if c.done != nil {
+			close(c.done)
+			<-c.stopped
+		}
+	})
+}
+
+// onEvicted buffers evicted entries for the eviction callback.
+// It is called with the lock held.
+func (c *LRU) onEvicted(k, v interface{}) {
+	if c.onEvict != nil {
+		c.evicted = append(c.evicted, simplelru.Entry{Key: k, Value: v.(*item).value})
+	}
+}
+
+// unlock releases the lock and invokes the eviction callback for the
+// entries evicted while it was held.
+func (c *LRU) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.lock.Unlock()
+	for _, e := range evicted {
+		c.onEvict(e.Key, e.Value)
+	}
+}
+
+// Add adds a value to the cache with the default TTL. Returns true if an
+// eviction occurred.
+func (c *LRU) Add(key, value interface{}) (evicted bool) {
+	return c.AddWithTTL(key, value, c.ttl)
+}
+
+// AddWithTTL adds a value to the cache that expires after ttl, or never if
+// ttl is zero or less. Adding an existing key replaces its TTL. Returns true
+// if an eviction occurred.
+func (c *LRU) AddWithTTL(key, value interface{}, ttl time.Duration) (evicted bool) {
+	it := &item{value: value, ttl: ttl}
+	c.lock.Lock()
+	it.epoch = c.epoch
+	it.touch(c.clock.Now())
+	evicted = c.lru.Add(key, it)
+	c.unlock()
+	return evicted
+}
+
+// Get looks up a key's value from the cache, updating its "recently
+// used"-ness, and its expiration if created WithExpireAfterAccess. An
+// expired entry is removed and reported as missing.
+func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if it := c.peek(key); it != nil {
+		c.lru.Get(key)
+		if c.afterAccess {
+			it.touch(c.clock.Now())
+		}
+		return it.value, true
+	}
+	return nil, false
+}
+
+// Peek returns a key's value without updating its "recently used"-ness.
+// An expired entry is removed and reported as missing.
+func (c *LRU) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if it := c.peek(key); it != nil {
+		return it.value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache and has not expired, without
+// updating its "recently used"-ness. An expired entry is removed.
+func (c *LRU) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.peek(key) != nil
+}
+
+// stale reports whether an item has expired at now or was added before the
+// last InvalidateAll. It must be called with the lock held.
+func (c *LRU) stale(it *item, now time.Time) bool {
+	return it.epoch != c.epoch || it.expired(now)
+}
+
+// peek returns the item of a key, removing it if expired or invalidated.
+// It must be called with the lock held.
+func (c *LRU) peek(key interface{}) *item {
+	v, ok := c.lru.Peek(key)
+	if !ok {
+		return nil
+	}
+	it := v.(*item)
+	if c.stale(it, c.clock.Now()) {
+		c.lru.Remove(key)
+		return nil
+	}
+	return it
+}
+
+// GetTTL returns the time left before a key expires, or zero if it never
+// expires, without updating its "recently used"-ness. An expired entry is
+// removed and reported as missing.
+func (c *LRU) GetTTL(key interface{}) (ttl time.Duration, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	it := c.peek(key)
+	if it == nil {
+		return 0, false
+	}
+	if it.expires.IsZero() {
+		return 0, true
+	}
+	return it.expires.Sub(c.clock.Now()), true
+}
+
+// SetTTL makes a key expire after ttl from now, or never if ttl is zero or
+// less, without updating its "recently used"-ness. The TTL replaces the
+// one the key was added with, including when refreshed on access. Returns
+// false if the key is missing or has expired.
+func (c *LRU) SetTTL(key interface{}, ttl time.Duration) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	it := c.peek(key)
+	if it == nil {
+		return false
+	}
+	it.ttl = ttl
+	it.touch(c.clock.Now())
+	return true
+}
+
+// ExtendTTL postpones the expiration of a key by d, without updating its
+// "recently used"-ness. Keys that never expire are left unchanged, and the
+// extension is lost when the expiration is refreshed on access. Returns
+// false if the key is missing or has expired.
+func (c *LRU) ExtendTTL(key interface{}, d time.Duration) bool {
+	c.lock.Lock()
+	defer c.unlock()
+	it := c.peek(key)
+	if it == nil {
+		return false
+	}
+	if !it.expires.IsZero() {
+		it.expires = it.expires.Add(d)
+	}
+	return true
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained, even if expired.
+func (c *LRU) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Remove(key)
+}
+
+// DeleteExpired removes all the expired entries, and those invalidated by
+// InvalidateAll, returning how many were removed. It takes time linear in
+// the length of the cache.
+func (c *LRU) DeleteExpired() int {
+	c.lock.Lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	n := 0
+	for _, k := range c.lru.Keys() {
+		v, _ := c.lru.Peek(k)
+		if c.stale(v.(*item), now) {
+			c.lru.Remove(k)
+			n++
+		}
+	}
+	return n
+}
+
+// Keys returns a slice of the keys in the cache that have not expired nor
+// been invalidated, from oldest to newest.
+func (c *LRU) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.clock.Now()
+	keys := c.lru.Keys()
+	live := keys[:0]
+	for _, k := range keys {
+		v, _ := c.lru.Peek(k)
+		if !c.stale(v.(*item), now) {
+			live = append(live, k)
+		}
+	}
+	return live
+}
+
+// Len returns the number of items in the cache, including expired entries
+// not removed yet.
+func (c *LRU) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// Resize changes the cache size, returning the number of entries evicted.
+func (c *LRU) Resize(size int) (evicted int) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Resize(size)
+}
+
+// InvalidateAll makes every entry currently in the cache stale, in
+// constant time, unlike Purge which removes them all at once under the
+// lock. Stale entries behave as expired: they are never returned, and are
+// removed, invoking the eviction callback, when looked up, by
+// DeleteExpired or the janitor, or when evicted for capacity. Until then
+// they still take up capacity and are counted by Len.
+func (c *LRU) InvalidateAll() {
+	c.lock.Lock()
+	c.epoch++
+	c.lock.Unlock()
+}
+
+// Purge is used to completely clear the cache.
+func (c *LRU) Purge() {
+	c.lock.Lock()
+	defer c.unlock()
+	c.lru.Purge()
+}
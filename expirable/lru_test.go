@@ -0,0 +1,310 @@
+package expirable
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a TickerClock advanced and ticked by hand.
+type fakeClock struct {
+	lock  sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000, 0), ticks: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+func (c *fakeClock) NewTicker(interval time.Duration) (<-chan time.Time, func()) {
+	return c.ticks, func() {}
+}
+
+// Tick delivers a tick to the janitor and waits for the pass it starts to
+// complete, which is once the janitor is ready for the next tick.
+func (c *fakeClock) Tick() {
+	c.ticks <- c.Now()
+	c.ticks <- c.Now()
+}
+
+func TestLRU(t *testing.T) {
+	var evicted []interface{}
+	clock := newFakeClock()
+	l, err := NewLRU(4, func(k, v interface{}) {
+		if k != v {
+			t.Fatalf("evict values not equal (%v!=%v)", k, v)
+		}
+		evicted = append(evicted, k)
+	}, time.Minute, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.AddWithTTL(2, 2, 2*time.Minute)
+	l.AddWithTTL(3, 3, 0)
+	clock.Advance(time.Minute)
+
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should have expired")
+	}
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Fatalf("expired entries should be evicted: %v", evicted)
+	}
+	if v, ok := l.Peek(2); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	clock.Advance(time.Minute)
+	if l.Contains(2) {
+		t.Fatalf("2 should have expired")
+	}
+	clock.Advance(time.Hour)
+	if v, ok := l.Get(3); !ok || v != 3 {
+		t.Fatalf("entries without TTL should not expire: %v %v", v, ok)
+	}
+
+	// Re-adding replaces the TTL
+	l.AddWithTTL(3, 3, time.Second)
+	clock.Advance(time.Second)
+	if l.Contains(3) {
+		t.Fatalf("3 should have expired")
+	}
+}
+
+func TestLRU_DeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRU(4, nil, time.Minute, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.AddWithTTL(3, 3, time.Hour)
+	clock.Advance(time.Minute)
+
+	if keys := l.Keys(); len(keys) != 1 || keys[0] != 3 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if l.Len() != 3 {
+		t.Fatalf("expired entries should count until removed: %v", l.Len())
+	}
+	if n := l.DeleteExpired(); n != 2 {
+		t.Fatalf("bad deleted: %v", n)
+	}
+	if l.Len() != 1 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Eviction(t *testing.T) {
+	var evicted []interface{}
+	l, err := NewLRU(2, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	}, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(1)
+	if !l.Add(3, 3) {
+		t.Fatalf("should evict")
+	}
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	l.Remove(1)
+	l.Purge()
+	if len(evicted) != 3 || l.Len() != 0 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if _, err := NewLRU(0, nil, 0); err == nil {
+		t.Fatalf("should fail on invalid size")
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRU(4, nil, time.Minute, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.AddWithTTL(2, 2, 0)
+	clock.Advance(20 * time.Second)
+	if ttl, ok := l.GetTTL(1); !ok || ttl != 40*time.Second {
+		t.Fatalf("bad: %v %v", ttl, ok)
+	}
+	if ttl, ok := l.GetTTL(2); !ok || ttl != 0 {
+		t.Fatalf("bad: %v %v", ttl, ok)
+	}
+	if _, ok := l.GetTTL(3); ok {
+		t.Fatalf("should be missing")
+	}
+
+	if !l.ExtendTTL(1, time.Minute) {
+		t.Fatalf("should extend")
+	}
+	if ttl, _ := l.GetTTL(1); ttl != 100*time.Second {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if !l.ExtendTTL(2, time.Minute) {
+		t.Fatalf("should extend")
+	}
+	if ttl, _ := l.GetTTL(2); ttl != 0 {
+		t.Fatalf("entries without TTL should not expire: %v", ttl)
+	}
+
+	if !l.SetTTL(2, time.Second) || !l.SetTTL(1, 0) {
+		t.Fatalf("should set")
+	}
+	clock.Advance(time.Hour)
+	if l.Contains(2) || !l.Contains(1) {
+		t.Fatalf("bad expiration")
+	}
+	if l.SetTTL(2, time.Minute) || l.ExtendTTL(2, time.Minute) {
+		t.Fatalf("expired keys should be missing")
+	}
+}
+
+func TestLRU_ExpireAfterAccess(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRU(4, nil, time.Minute, WithExpireAfterAccess(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	for i := 0; i < 5; i++ {
+		clock.Advance(30 * time.Second)
+		if _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should be kept alive by lookups")
+		}
+	}
+	if l.Contains(2) {
+		t.Fatalf("2 should have expired while idle")
+	}
+
+	// Peek does not refresh the expiration
+	clock.Advance(30 * time.Second)
+	l.Peek(1)
+	clock.Advance(30 * time.Second)
+	if _, ok := l.Peek(1); ok {
+		t.Fatalf("1 should have expired")
+	}
+
+	// SetTTL replaces the TTL refreshed on access
+	l.Add(3, 3)
+	l.SetTTL(3, time.Hour)
+	clock.Advance(59 * time.Minute)
+	l.Get(3)
+	clock.Advance(59 * time.Minute)
+	if !l.Contains(3) {
+		t.Fatalf("3 should be kept alive by lookups")
+	}
+}
+
+func TestLRU_InvalidateAll(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []interface{}
+	onEvict := func(k, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewLRU(4, onEvict, time.Minute, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.InvalidateAll()
+	if len(evicted) != 0 {
+		t.Fatalf("InvalidateAll should not remove entries: %v", evicted)
+	}
+	if l.Len() != 3 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if keys := l.Keys(); len(keys) != 0 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// Stale entries are removed when looked up
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should be stale")
+	}
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+
+	// Entries added afterwards are live
+	l.Add(2, 20)
+	l.Add(4, 4)
+	if v, ok := l.Get(2); !ok || v != 20 {
+		t.Fatalf("bad: %v, %v", v, ok)
+	}
+	if n := l.DeleteExpired(); n != 1 {
+		t.Fatalf("should have removed 3: %v", n)
+	}
+	if l.Len() != 2 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Janitor(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRU(4, nil, time.Minute, WithClock(clock), WithJanitor(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	l.Add(1, 1)
+	l.AddWithTTL(2, 2, time.Hour)
+	clock.Advance(time.Minute)
+	clock.Tick()
+	if l.Len() != 1 {
+		t.Fatalf("janitor should remove expired entries")
+	}
+
+	l.Close()
+	l.Close()
+	select {
+	case <-l.stopped:
+	default:
+		t.Fatalf("janitor should have stopped")
+	}
+	select {
+	case clock.ticks <- clock.Now():
+		t.Fatalf("janitor should not take ticks once closed")
+	default:
+	}
+	l.Add(3, 3)
+	clock.Advance(time.Minute)
+	if l.Len() != 2 {
+		t.Fatalf("janitor should stop once closed")
+	}
+	if _, ok := l.Get(3); ok {
+		t.Fatalf("3 should have expired")
+	}
+
+	// Closing a cache without janitor is a no-op
+	l2, _ := NewLRU(1, nil, 0)
+	l2.Close()
+}
@@ -0,0 +1,23 @@
+package lru
+
+import (
+	"expvar"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// StatsProvider is a cache reporting its usage counters. Cache,
+// TwoQueueCache and ARCCache implement it.
+type StatsProvider interface {
+	Stats() simplelru.Stats
+}
+
+// PublishExpvar publishes the live stats of a cache as the expvar variable
+// name, shown at /debug/vars by the expvar HTTP handler. The stats are
+// taken each time the variable is read. Like expvar.Publish, it panics if
+// a variable of the same name is already published.
+func PublishExpvar(name string, c StatsProvider) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return c.Stats()
+	}))
+}
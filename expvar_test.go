@@ -0,0 +1,37 @@
+package lru
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// expvarRuns keeps published names unique when tests run several times
+var expvarRuns int
+
+func TestPublishExpvar(t *testing.T) {
+	l, err := New2Q(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expvarRuns++
+	name := fmt.Sprintf("TestPublishExpvar%d", expvarRuns)
+	PublishExpvar(name, l)
+	l.Add(1, 1)
+	l.Get(1)
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("should be published")
+	}
+	var st simplelru.Stats
+	if err := json.Unmarshal([]byte(v.String()), &st); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if st.Hits != 1 || st.Adds != 1 || st.Len != 1 || st.Cap != 4 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
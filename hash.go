@@ -0,0 +1,10 @@
+package lru
+
+import "github.com/hashicorp/golang-lru/simplelru"
+
+// HashKey hashes keys with simplelru.HashKey: FNV-1a over the key, walking
+// structs and arrays field by field, with pointers and channels hashed by
+// address. It does not allocate.
+func HashKey(key interface{}) uint64 {
+	return simplelru.HashKey(key)
+}
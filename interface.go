@@ -0,0 +1,105 @@
+package lru
+
+// Interface is the set of methods shared by the caches of this package,
+// whatever their eviction policy, so that applications can swap policies,
+// for instance from configuration, without code changes. All the
+// implementations are safe for concurrent use. TwoQueueCache and ARCCache
+// keep their Add and Remove reporting nothing, for compatibility, and are
+// adapted to Interface by their AsInterface method.
+type Interface interface {
+	// Add adds a value to the cache, returning true if an eviction
+	// occurred. Adding an existing key counts as an access.
+	Add(key, value interface{}) (evicted bool)
+
+	// Get returns a key's value from the cache, counting as an access.
+	Get(key interface{}) (value interface{}, ok bool)
+
+	// Peek returns a key's value without counting as an access.
+	Peek(key interface{}) (value interface{}, ok bool)
+
+	// Contains checks if a key is in the cache without counting as an
+	// access.
+	Contains(key interface{}) bool
+
+	// Remove removes a key from the cache, returning if it was contained.
+	Remove(key interface{}) (present bool)
+
+	// Len returns the number of items in the cache.
+	Len() int
+
+	// Keys returns a slice of the keys in the cache, in an order specific
+	// to the policy.
+	Keys() []interface{}
+
+	// Purge clears all cache entries.
+	Purge()
+
+	// Resize changes the cache size, returning the number of entries
+	// evicted.
+	Resize(size int) (evicted int)
+}
+
+var (
+	_ Interface = (*Cache)(nil)
+	_ Interface = twoQueueInterface{}
+	_ Interface = arcInterface{}
+	_ Interface = (*LFUCache)(nil)
+	_ Interface = (*SLRUCache)(nil)
+	_ Interface = (*TinyLFUCache)(nil)
+	_ Interface = (*ClockCache)(nil)
+	_ Interface = (*SieveCache)(nil)
+	_ Interface = (*S3FIFOCache)(nil)
+	_ Interface = (*LRUKCache)(nil)
+
+	_ func() Interface = (*TwoQueueCache)(nil).AsInterface
+	_ func() Interface = (*ARCCache)(nil).AsInterface
+)
+
+// twoQueueInterface adapts a TwoQueueCache to Interface, whose Add and
+// Remove report what they did, unlike those of TwoQueueCache.
+type twoQueueInterface struct {
+	*TwoQueueCache
+}
+
+// AsInterface returns the cache as an Interface, whatever the parameters it
+// was created with. The returned value shares the entries of the cache.
+func (c *TwoQueueCache) AsInterface() Interface {
+	return twoQueueInterface{c}
+}
+
+func (c twoQueueInterface) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	_, _, evicted = c.add(key, value)
+	return evicted
+}
+
+func (c twoQueueInterface) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.remove(key)
+}
+
+// arcInterface adapts an ARCCache to Interface, whose Add and Remove
+// report what they did, unlike those of ARCCache.
+type arcInterface struct {
+	*ARCCache
+}
+
+// AsInterface returns the cache as an Interface. The returned value shares
+// the entries of the cache.
+func (c *ARCCache) AsInterface() Interface {
+	return arcInterface{c}
+}
+
+func (c arcInterface) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.add(key, value)
+}
+
+func (c arcInterface) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.remove(key)
+}
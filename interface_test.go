@@ -0,0 +1,122 @@
+package lru
+
+import "testing"
+
+// Test that every policy behaves the same through Interface
+func TestInterface(t *testing.T) {
+	for _, name := range Policies() {
+		l, err := NewByPolicy(name, 64)
+		if err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		evictions := 0
+		for i := 0; i < 128; i++ {
+			if l.Add(i, i) {
+				evictions++
+			}
+			l.Get(i)
+		}
+		if l.Len() != 64 || len(l.Keys()) != 64 || evictions != 64 {
+			t.Fatalf("%s: bad len: %v %v %v", name, l.Len(), len(l.Keys()), evictions)
+		}
+
+		k := l.Keys()[0]
+		if v, ok := l.Peek(k); !ok || v != k || !l.Contains(k) {
+			t.Fatalf("%s: bad peek: %v %v", name, v, ok)
+		}
+		if !l.Remove(k) || l.Remove(k) || l.Contains(k) {
+			t.Fatalf("%s: bad remove", name)
+		}
+
+		if evicted := l.Resize(32); evicted != 31 || l.Len() != 32 {
+			t.Fatalf("%s: bad resize: %v %v", name, evicted, l.Len())
+		}
+		for i := 1000; i < 1100; i++ {
+			l.Add(i, i)
+			l.Get(i)
+		}
+		if l.Len() != 32 {
+			t.Fatalf("%s: bad len: %v", name, l.Len())
+		}
+		if evicted := l.Resize(48); evicted != 0 {
+			t.Fatalf("%s: bad resize: %v", name, evicted)
+		}
+		for i := 2000; i < 2100; i++ {
+			l.Add(i, i)
+			l.Get(i)
+		}
+		if l.Len() != 48 {
+			t.Fatalf("%s: bad len: %v", name, l.Len())
+		}
+
+		l.Purge()
+		if l.Len() != 0 {
+			t.Fatalf("%s: bad len: %v", name, l.Len())
+		}
+	}
+}
+
+func TestNewByPolicy(t *testing.T) {
+	l, err := NewByPolicy("ARC", 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.(arcInterface); !ok {
+		t.Fatalf("bad cache: %T", l)
+	}
+	if _, err := NewByPolicy("mru", 8); err == nil {
+		t.Fatalf("should reject unknown policy")
+	}
+	if _, err := NewByPolicy("lru", 0); err == nil {
+		t.Fatalf("should reject size")
+	}
+
+	RegisterPolicy("2Q-Half", func(size int) (Interface, error) {
+		c, err := New2QParams(size, 0.5, Default2QGhostEntries)
+		if err != nil {
+			return nil, err
+		}
+		return c.AsInterface(), nil
+	})
+	defer func() {
+		policiesLock.Lock()
+		delete(policies, "2q-half")
+		policiesLock.Unlock()
+	}()
+	l, err = NewByPolicy("2q-half", 8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r := l.(twoQueueInterface).RecentRatio(); r != 0.5 {
+		t.Fatalf("bad ratio: %v", r)
+	}
+}
+
+// Test that AsInterface adapts caches created with custom parameters
+func TestAsInterface(t *testing.T) {
+	q, err := New2QParams(4, 0.5, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a, err := NewARC(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, l := range []Interface{q.AsInterface(), a.AsInterface()} {
+		evictions := 0
+		for i := 0; i < 6; i++ {
+			if l.Add(i, i) {
+				evictions++
+			}
+		}
+		if evictions != 2 || l.Len() != 4 {
+			t.Fatalf("%T: bad evictions: %v %v", l, evictions, l.Len())
+		}
+		if !l.Remove(5) || l.Remove(5) {
+			t.Fatalf("%T: bad remove", l)
+		}
+	}
+	if q.Contains(5) || q.Len() != 3 || a.Contains(5) || a.Len() != 3 {
+		t.Fatalf("adapters should share the entries of the caches")
+	}
+}
@@ -0,0 +1,54 @@
+package lru
+
+import (
+	"encoding/gob"
+	"io"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// SaveKeys writes the keys of the cache to w, from oldest to newest, using
+// encoding/gob. Values are not written: the keys are meant to be passed to
+// LoadKeys on restart, and the cache re-warmed by loading and adding the
+// value of each key in order, which restores the recency order. Keys must be
+// encodable by gob, with any named or struct types registered using
+// gob.Register, otherwise an error is returned. The lock is not held while
+// writing to w.
+func (c *Cache) SaveKeys(w io.Writer) error {
+	keys := c.Keys()
+	return gob.NewEncoder(w).Encode(keys)
+}
+
+// LoadKeys reads keys written by SaveKeys from r, from oldest to newest.
+func LoadKeys(r io.Reader) ([]interface{}, error) {
+	var keys []interface{}
+	if err := gob.NewDecoder(r).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Snapshot writes the entries of the cache to w, from oldest to newest,
+// using encoding/gob, so that Restore can rebuild the cache with the same
+// recency order and services restart warm. Keys and values must be
+// encodable by gob, with any named or struct types registered using
+// gob.Register, otherwise an error is returned. The lock is not held while
+// writing to w.
+func (c *Cache) Snapshot(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(c.Items())
+}
+
+// Restore reads entries written by Snapshot from r and adds them in order,
+// like AddMany, so that the last entry written is the most recently used.
+// Existing entries are kept, older than the restored ones; call Purge first
+// to replace them. If the snapshot holds more entries than the cache size,
+// the oldest are evicted. Nothing is added if the snapshot cannot be
+// decoded. The lock is not held while reading from r.
+func (c *Cache) Restore(r io.Reader) error {
+	var items []simplelru.Entry
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return err
+	}
+	c.AddMany(items)
+	return nil
+}
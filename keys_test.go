@@ -0,0 +1,108 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+type savedKey struct {
+	ID int
+}
+
+// unsavedKey is never registered with gob
+type unsavedKey struct {
+	ID int
+}
+
+func TestCacheSaveKeys(t *testing.T) {
+	l, err := New(3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, "a")
+	l.Add("two", "b")
+	l.Add(3, "c")
+	l.Get(1)
+
+	var buf bytes.Buffer
+	if err := l.SaveKeys(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	keys, err := LoadKeys(&buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []interface{}{"two", 3, 1}
+	if len(keys) != len(want) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("bad keys: %v", keys)
+		}
+	}
+
+	// Unregistered key types fail cleanly
+	l.Add(unsavedKey{4}, "d")
+	if err := l.SaveKeys(&bytes.Buffer{}); err == nil {
+		t.Fatalf("should fail on unregistered key type")
+	}
+	l.Remove(unsavedKey{4})
+	gob.Register(savedKey{})
+	l.Add(savedKey{4}, "d")
+	buf.Reset()
+	if err := l.SaveKeys(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	keys, err = LoadKeys(&buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys[len(keys)-1] != (savedKey{4}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	if _, err := LoadKeys(bytes.NewReader([]byte("junk"))); err == nil {
+		t.Fatalf("should fail on invalid input")
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	l, err := New(3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, "a")
+	l.Add("two", "b")
+	l.Add(3, "c")
+	l.Get(1)
+
+	var buf bytes.Buffer
+	if err := l.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r, err := New(3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r.Add(4, "d")
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !equalKeys(r.Keys(), []interface{}{"two", 3, 1}) {
+		t.Fatalf("bad keys: %v", r.Keys())
+	}
+	if v, _ := r.Peek("two"); v != "b" {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	// Unregistered value types fail cleanly
+	l.Add(5, unsavedKey{5})
+	if err := l.Snapshot(&bytes.Buffer{}); err == nil {
+		t.Fatalf("should fail on unregistered value type")
+	}
+	if err := r.Restore(bytes.NewReader([]byte("junk"))); err == nil {
+		t.Fatalf("should fail on invalid input")
+	}
+}
@@ -0,0 +1,317 @@
+package lru
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// LFUCache is a thread-safe fixed size Least Frequently Used cache. It
+// evicts the entry accessed the fewest times, breaking ties by evicting the
+// least recently used one, which suits workloads where popularity matters
+// more than recency. Entries are kept in buckets of equal frequency, so
+// every operation takes constant time. Frequencies can optionally be aged,
+// so that formerly popular entries do not stay forever.
+type LFUCache struct {
+	size  int
+	items map[interface{}]*lfuEntry
+	freqs *list.List // of *lfuBucket, by increasing frequency
+
+	agingPeriod int
+	accesses    int
+
+	onEvict simplelru.EvictCallback
+	evicted []simplelru.Entry
+	stats   stats
+	lock    sync.Mutex
+}
+
+// lfuBucket holds the entries accessed freq times, newest first.
+type lfuBucket struct {
+	freq    int
+	entries *list.List
+}
+
+// lfuEntry is an entry of an LFUCache along with its position.
+type lfuEntry struct {
+	key, value interface{}
+	bucket     *list.Element
+	elem       *list.Element
+}
+
+// NewLFU creates an LFUCache of the given size.
+func NewLFU(size int) (*LFUCache, error) {
+	return NewLFUWithEvict(size, nil)
+}
+
+// NewLFUWithEvict creates an LFUCache of the given size with an eviction
+// callback, invoked outside of the lock for entries evicted or removed.
+func NewLFUWithEvict(size int, onEvict simplelru.EvictCallback) (*LFUCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	c := &LFUCache{
+		size:    size,
+		items:   make(map[interface{}]*lfuEntry),
+		freqs:   list.New(),
+		onEvict: onEvict,
+	}
+	return c, nil
+}
+
+// unlock releases the lock and invokes the eviction callback for the
+// entries removed while it was held.
+func (c *LFUCache) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.lock.Unlock()
+	for _, e := range evicted {
+		c.onEvict(e.Key, e.Value)
+	}
+}
+
+// SetAgingPeriod halves the frequency of every entry, rounding up, after
+// each period lookups and adds, so that entries popular in the past make
+// way for currently popular ones. Aging takes time linear in the length of
+// the cache. A period of zero or less disables aging, the default.
+func (c *LFUCache) SetAgingPeriod(period int) {
+	c.lock.Lock()
+	c.agingPeriod = period
+	c.accesses = 0
+	c.lock.Unlock()
+}
+
+// Age halves the frequency of every entry, rounding up, keeping their
+// order. It takes time linear in the length of the cache.
+func (c *LFUCache) Age() {
+	c.lock.Lock()
+	c.age()
+	c.lock.Unlock()
+}
+
+// age halves all frequencies. It must be called with the lock held.
+func (c *LFUCache) age() {
+	aged := list.New()
+	for b := c.freqs.Front(); b != nil; b = b.Next() {
+		old := b.Value.(*lfuBucket)
+		freq := (old.freq + 1) / 2
+		to := aged.Back()
+		if to == nil || to.Value.(*lfuBucket).freq != freq {
+			to = aged.PushBack(&lfuBucket{freq: freq, entries: list.New()})
+		}
+		// Entries from the more frequent bucket go first, as if newer
+		entries := to.Value.(*lfuBucket).entries
+		for e := old.entries.Back(); e != nil; e = e.Prev() {
+			ent := e.Value.(*lfuEntry)
+			ent.bucket = to
+			ent.elem = entries.PushFront(ent)
+		}
+	}
+	c.freqs = aged
+}
+
+// access counts an access for aging. It must be called with the lock held.
+func (c *LFUCache) access() {
+	if c.agingPeriod <= 0 {
+		return
+	}
+	c.accesses++
+	if c.accesses >= c.agingPeriod {
+		c.accesses = 0
+		c.age()
+	}
+}
+
+// increment moves an entry to the bucket of the next frequency.
+func (c *LFUCache) increment(ent *lfuEntry) {
+	cur := ent.bucket
+	b := cur.Value.(*lfuBucket)
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != b.freq+1 {
+		next = c.freqs.InsertAfter(&lfuBucket{freq: b.freq + 1, entries: list.New()}, cur)
+	}
+	b.entries.Remove(ent.elem)
+	ent.elem = next.Value.(*lfuBucket).entries.PushFront(ent)
+	ent.bucket = next
+	if b.entries.Len() == 0 {
+		c.freqs.Remove(cur)
+	}
+}
+
+// unlink removes an entry, recording it for the eviction callback.
+func (c *LFUCache) unlink(ent *lfuEntry) {
+	b := ent.bucket.Value.(*lfuBucket)
+	b.entries.Remove(ent.elem)
+	if b.entries.Len() == 0 {
+		c.freqs.Remove(ent.bucket)
+	}
+	delete(c.items, ent.key)
+	if c.onEvict != nil {
+		c.evicted = append(c.evicted, simplelru.Entry{Key: ent.key, Value: ent.value})
+	}
+}
+
+// evict removes the least recently used of the least frequently used
+// entries. It must be called with the lock held.
+func (c *LFUCache) evict() {
+	least := c.freqs.Front().Value.(*lfuBucket)
+	c.unlink(least.entries.Back().Value.(*lfuEntry))
+	c.stats.evictions++
+}
+
+// Add adds a value to the cache, counting as an access of an existing key.
+// Returns true if an eviction occurred.
+func (c *LFUCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	c.stats.adds++
+	if ent, ok := c.items[key]; ok {
+		ent.value = value
+		c.increment(ent)
+		c.access()
+		return false
+	}
+
+	if len(c.items) >= c.size {
+		c.evict()
+		evicted = true
+	}
+	first := c.freqs.Front()
+	if first == nil || first.Value.(*lfuBucket).freq != 1 {
+		first = c.freqs.PushFront(&lfuBucket{freq: 1, entries: list.New()})
+	}
+	ent := &lfuEntry{key: key, value: value, bucket: first}
+	ent.elem = first.Value.(*lfuBucket).entries.PushFront(ent)
+	c.items[key] = ent
+	c.access()
+	return evicted
+}
+
+// Get looks up a key's value from the cache, incrementing its frequency.
+func (c *LFUCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ent, ok := c.items[key]
+	if !ok {
+		c.stats.misses++
+		return nil, false
+	}
+	c.stats.hits++
+	c.increment(ent)
+	c.access()
+	return ent.value, true
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the frequency of the key.
+func (c *LFUCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		return ent.value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without updating its
+// frequency.
+func (c *LFUCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Frequency returns the number of accesses counted for a key since it was
+// added, as possibly halved by aging.
+func (c *LFUCache) Frequency(key interface{}) (freq int, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		return ent.bucket.Value.(*lfuBucket).freq, true
+	}
+	return 0, false
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *LFUCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	if ent, ok := c.items[key]; ok {
+		c.unlink(ent)
+		return true
+	}
+	return false
+}
+
+// Resize changes the cache size, returning the number of entries evicted.
+// Sizes below 1 are raised to 1.
+func (c *LFUCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.unlock()
+	c.size = size
+	for len(c.items) > size {
+		c.evict()
+		evicted++
+	}
+	return evicted
+}
+
+// Keys returns a slice of the keys in the cache, in eviction order: from
+// the least to the most frequently used, and from the least to the most
+// recently used among keys of equal frequency.
+func (c *LFUCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	keys := make([]interface{}, 0, len(c.items))
+	for b := c.freqs.Front(); b != nil; b = b.Next() {
+		for e := b.Value.(*lfuBucket).entries.Back(); e != nil; e = e.Prev() {
+			keys = append(keys, e.Value.(*lfuEntry).key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *LFUCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.items)
+}
+
+// Purge is used to completely clear the cache.
+func (c *LFUCache) Purge() {
+	c.lock.Lock()
+	defer c.unlock()
+	if c.onEvict != nil {
+		for b := c.freqs.Front(); b != nil; b = b.Next() {
+			for e := b.Value.(*lfuBucket).entries.Back(); e != nil; e = e.Prev() {
+				ent := e.Value.(*lfuEntry)
+				c.evicted = append(c.evicted, simplelru.Entry{Key: ent.key, Value: ent.value})
+			}
+		}
+	}
+	c.items = make(map[interface{}]*lfuEntry)
+	c.freqs.Init()
+}
+
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity.
+func (c *LFUCache) Stats() simplelru.Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats.snapshot(len(c.items), c.size)
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *LFUCache) ResetStats() {
+	c.lock.Lock()
+	c.stats = stats{}
+	c.lock.Unlock()
+}
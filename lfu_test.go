@@ -0,0 +1,128 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkLFU_Rand(b *testing.B) {
+	l, err := NewLFU(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestLFU(t *testing.T) {
+	var evicted []interface{}
+	l, err := NewLFUWithEvict(3, func(k, v interface{}) {
+		if k != v {
+			t.Fatalf("evict values not equal (%v!=%v)", k, v)
+		}
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+	l.Get(1)
+	l.Get(2)
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 3 || keys[1] != 2 || keys[2] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// 3 is the least frequently used
+	if !l.Add(4, 4) {
+		t.Fatalf("should evict")
+	}
+	if len(evicted) != 1 || evicted[0] != 3 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	// Ties go to the least recently used: 4 was used after 2 reached 2
+	l.Get(4)
+	l.Add(5, 5)
+	if l.Contains(2) || !l.Contains(4) {
+		t.Fatalf("bad tie break: %v", l.Keys())
+	}
+
+	if f, ok := l.Frequency(1); !ok || f != 3 {
+		t.Fatalf("bad frequency: %v %v", f, ok)
+	}
+	if v, ok := l.Peek(1); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if f, _ := l.Frequency(1); f != 3 {
+		t.Fatalf("Peek should not count: %v", f)
+	}
+
+	l.Remove(1)
+	if l.Contains(1) || l.Len() != 2 {
+		t.Fatalf("should remove")
+	}
+	l.Purge()
+	if l.Len() != 0 || len(l.Keys()) != 0 || len(evicted) != 5 {
+		t.Fatalf("bad purge: %v", evicted)
+	}
+	if _, err := NewLFU(0); err == nil {
+		t.Fatalf("should reject zero size")
+	}
+}
+
+func TestLFU_Aging(t *testing.T) {
+	l, err := NewLFU(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	for i := 0; i < 6; i++ {
+		l.Get(1)
+	}
+	l.Add(2, 2)
+	l.Get(2)
+	l.Age()
+	if f, _ := l.Frequency(1); f != 4 {
+		t.Fatalf("bad frequency: %v", f)
+	}
+	if f, _ := l.Frequency(2); f != 1 {
+		t.Fatalf("bad frequency: %v", f)
+	}
+
+	// Every 4 accesses halves the frequencies
+	l.SetAgingPeriod(4)
+	for i := 0; i < 4; i++ {
+		l.Get(2)
+	}
+	if f, _ := l.Frequency(1); f != 2 {
+		t.Fatalf("bad frequency: %v", f)
+	}
+	if f, _ := l.Frequency(2); f != 3 {
+		t.Fatalf("bad frequency: %v", f)
+	}
+	if keys := l.Keys(); keys[0] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
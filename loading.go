@@ -0,0 +1,107 @@
+package lru
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrLoadPanicked is returned by LoadingCache.Get and GetOrCompute to the
+// lookups sharing a call of the loader or compute function that panicked.
+// The lookup that made the call panics instead.
+var ErrLoadPanicked = errors.New("loader panicked")
+
+// loadCall is an in-flight call of a loader or compute function.
+type loadCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// flightGroup coalesces concurrent calls for the same key into a single
+// one. The zero value is ready to use.
+type flightGroup struct {
+	lock  sync.Mutex
+	calls map[interface{}]*loadCall
+}
+
+// do calls fn for a key and returns its result, unless a call for the key
+// is already in flight, in which case it waits for that call and returns
+// its result instead. If fn panics, the panic propagates to the caller and
+// the waiters get ErrLoadPanicked.
+func (g *flightGroup) do(key interface{}, fn func() (interface{}, error)) (value interface{}, err error) {
+	g.lock.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[interface{}]*loadCall)
+	}
+	call := new(loadCall)
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.lock.Unlock()
+
+	// Release the waiters even if fn panics, in which case the error is
+	// left as is
+	call.err = ErrLoadPanicked
+	defer func() {
+		g.lock.Lock()
+		delete(g.calls, key)
+		g.lock.Unlock()
+		call.wg.Done()
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err
+}
+
+// LoadingCache is a thread-safe fixed size LRU cache that loads the values
+// of missing keys. Concurrent lookups of a missing key share a single call
+// of the loader, the others waiting for its result.
+type LoadingCache struct {
+	cache   *Cache
+	load    func(key interface{}) (interface{}, error)
+	flights flightGroup
+}
+
+// NewLoading creates a LoadingCache of the given size that loads missing
+// values with load.
+func NewLoading(size int, load func(key interface{}) (interface{}, error)) (*LoadingCache, error) {
+	cache, err := New(size)
+	if err != nil {
+		return nil, err
+	}
+	c := &LoadingCache{
+		cache: cache,
+		load:  load,
+	}
+	return c, nil
+}
+
+// Get looks up a key's value from the cache, loading and adding it if it
+// is missing. An error returned by the loader is returned to every lookup
+// sharing the call, and nothing is cached, so the next lookup loads again.
+// If the loader panics, the panic propagates to the lookup that called it,
+// the others sharing the call get ErrLoadPanicked, and the next lookup
+// loads again.
+func (c *LoadingCache) Get(key interface{}) (value interface{}, err error) {
+	if value, ok := c.cache.Get(key); ok {
+		return value, nil
+	}
+
+	return c.flights.do(key, func() (interface{}, error) {
+		value, err := c.load(key)
+		if err == nil {
+			c.cache.Add(key, value)
+		}
+		return value, err
+	})
+}
+
+// Cache returns the underlying cache, to inspect or modify its entries
+// directly. A key removed while its value is being loaded is added back
+// once the load completes.
+func (c *LoadingCache) Cache() *Cache {
+	return c.cache
+}
@@ -0,0 +1,115 @@
+package lru
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoadingCache(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	errOdd := errors.New("odd key")
+	l, err := NewLoading(2, func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		if key.(int)%2 != 0 {
+			return nil, errOdd
+		}
+		return key.(int) * 10, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	get := func() {
+		defer wg.Done()
+		if v, err := l.Get(2); v != 20 || err != nil {
+			t.Errorf("bad: %v %v", v, err)
+		}
+	}
+	wg.Add(1)
+	go get()
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go get()
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("bad call count: %v", n)
+	}
+	if v, ok := l.Cache().Peek(2); !ok || v != 20 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	// Errors are returned and not cached
+	for i := 0; i < 2; i++ {
+		if v, err := l.Get(3); v != nil || err != errOdd {
+			t.Fatalf("bad: %v %v", v, err)
+		}
+	}
+	if l.Cache().Contains(3) {
+		t.Fatalf("errors should not be cached")
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("bad call count: %v", n)
+	}
+
+	if _, err := NewLoading(0, nil); err == nil {
+		t.Fatalf("should reject zero size")
+	}
+}
+
+// Test that a panicking loader does not block later lookups of the key
+func TestLoadingCache_Panic(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	l, err := NewLoading(2, func(key interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			panic("boom")
+		}
+		return key, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		l.Get("a")
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	waiter := make(chan error)
+	go func() {
+		_, err := l.Get("a")
+		waiter <- err
+	}()
+	// Let the waiter join the call before the loader panics
+	for i := 0; i < 100; i++ {
+		runtime.Gosched()
+	}
+	close(release)
+	if r := <-panicked; r != "boom" {
+		t.Fatalf("bad panic: %v", r)
+	}
+	// The waiter either shared the call or loaded again
+	if err := <-waiter; err != nil && err != ErrLoadPanicked {
+		t.Fatalf("err: %v", err)
+	}
+	if v, err := l.Get("a"); v != "a" || err != nil {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+}
@@ -1,6 +1,7 @@
 package lru
 
 import (
+	"context"
 	"sync"
 
 	"github.com/hashicorp/golang-lru/simplelru"
@@ -11,12 +12,30 @@ const (
 	DefaultEvictedBufferSize = 16
 )
 
+// ErrMaxSize is returned by ResizeChecked when the requested size exceeds
+// the maximum size of the cache.
+var ErrMaxSize = simplelru.ErrMaxSize
+
 // Cache is a thread-safe fixed size LRU cache.
 type Cache struct {
-	lru                      *simplelru.LRU
-	evictedKeys, evictedVals []interface{}
-	onEvictedCB              func(k, v interface{})
-	lock                     sync.RWMutex
+	lru                 *simplelru.LRU
+	evicted             []evictedEntry
+	onEvictedCB         func(k, v interface{})
+	onEmpty, onNonEmpty func()
+	transitions         transitionQueue
+	watermark           *lenWatermark
+	keyHandlers         map[interface{}]func(value interface{})
+	tags                *tagIndex
+	subscribers         []*subscriber
+	droppedEvents       uint64
+	evictRate           *evictionRate
+	shadow              *shadow
+	overflow            *overflow
+	removing            bool
+	safeCallbacks       bool
+	onPanic             func(recovered interface{})
+	flights             flightGroup
+	lock                sync.RWMutex
 }
 
 // New creates an LRU of the given size.
@@ -27,72 +46,282 @@ func New(size int) (*Cache, error) {
 // NewWithEvict constructs a fixed size cache with the given eviction
 // callback.
 func NewWithEvict(size int, onEvicted func(key, value interface{})) (c *Cache, err error) {
-	// create a cache with default settings
-	c = &Cache{
+	c = newCache(onEvicted)
+	c.lru, err = simplelru.NewLRU(size, c.onEvicted)
+	return
+}
+
+// NewWithMax constructs a fixed size cache with the given eviction
+// callback that can never be resized beyond maxSize. Resize requests
+// above maxSize are clamped to it; ResizeChecked reports them.
+func NewWithMax(size, maxSize int, onEvicted func(key, value interface{})) (c *Cache, err error) {
+	c = newCache(onEvicted)
+	c.lru, err = simplelru.NewLRUWithMax(size, maxSize, c.onEvicted)
+	return
+}
+
+// newCache creates a cache with default settings, without its LRU.
+func newCache(onEvicted func(key, value interface{})) *Cache {
+	c := &Cache{
 		onEvictedCB: onEvicted,
 	}
 	if onEvicted != nil {
 		c.initEvictBuffers()
-		onEvicted = c.onEvicted
 	}
-	c.lru, err = simplelru.NewLRU(size, onEvicted)
-	return
+	return c
 }
 
 func (c *Cache) initEvictBuffers() {
-	c.evictedKeys = make([]interface{}, 0, DefaultEvictedBufferSize)
-	c.evictedVals = make([]interface{}, 0, DefaultEvictedBufferSize)
+	c.evicted = make([]evictedEntry, 0, DefaultEvictedBufferSize)
+}
+
+// lenTransition returns the callback registered for the change from before
+// to the current length, or nil if the cache did not become empty or
+// non-empty. It must be called with the lock held.
+func (c *Cache) lenTransition(before int) func() {
+	after := c.lru.Len()
+	switch {
+	case before == 0 && after > 0:
+		return c.onNonEmpty
+	case before > 0 && after == 0:
+		return c.onEmpty
+	}
+	return nil
 }
 
 // onEvicted save evicted key/val and sent in externally registered callback
 // outside of critical section
 func (c *Cache) onEvicted(k, v interface{}) {
-	c.evictedKeys = append(c.evictedKeys, k)
-	c.evictedVals = append(c.evictedVals, v)
+	if c.removing {
+		c.publish(EventRemove, k, v)
+	} else {
+		c.publish(EventEvict, k, v)
+		if c.evictRate != nil {
+			c.evictRate.record()
+		}
+		c.overflowAdd(k, v)
+	}
+	if c.tags != nil {
+		if c.removing || c.overflow == nil {
+			c.tags.remove(k)
+		} else {
+			c.pruneTags()
+		}
+	}
+	onEvict := c.keyHandlers[k]
+	if onEvict != nil {
+		delete(c.keyHandlers, k)
+	}
+	if c.onEvictedCB != nil || onEvict != nil {
+		c.evicted = append(c.evicted, evictedEntry{k, v, onEvict})
+	}
 }
 
-// Purge is used to completely clear the cache.
+// Purge is used to completely clear the cache. The eviction callback
+// is invoked for each entry from oldest to newest.
 func (c *Cache) Purge() {
-	var ks, vs []interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
 	c.lru.Purge()
-	if c.onEvictedCB != nil && len(c.evictedKeys) > 0 {
-		ks, vs = c.evictedKeys, c.evictedVals
-		c.initEvictBuffers()
-	}
+	c.removing = false
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	// invoke callback outside of critical section
-	if c.onEvictedCB != nil {
-		for i := 0; i < len(ks); i++ {
-			c.onEvictedCB(ks[i], vs[i])
+	cb.invoke(c)
+}
+
+// PurgeReverse is used to completely clear the cache like Purge, but
+// invokes the eviction callback for each entry from newest to oldest.
+func (c *Cache) PurgeReverse() {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
+	c.lru.PurgeReverse()
+	c.removing = false
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+}
+
+// PurgeCtx removes entries from oldest to newest like Purge, invoking the
+// eviction callbacks outside of the critical section after each removal,
+// until the cache is empty or ctx is done. It returns the number of entries
+// removed and, if it stopped early, ctx.Err(); the cache is then left
+// partially purged. At most as many entries as the cache held when PurgeCtx
+// was called are removed, so entries added concurrently may survive.
+func (c *Cache) PurgeCtx(ctx context.Context) (purged int, err error) {
+	remaining := c.Len()
+	for ; purged < remaining; purged++ {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		c.lock.Lock()
+		before := c.lru.Len()
+		c.removing = true
+		_, _, ok := c.lru.RemoveOldest()
+		c.removing = false
+		cb := c.takeCallbacks(before)
+		c.lock.Unlock()
+		cb.invoke(c)
+		if !ok {
+			break
 		}
 	}
+	return purged, nil
 }
 
 // Add adds a value to the cache. Returns true if an eviction occurred.
 func (c *Cache) Add(key, value interface{}) (evicted bool) {
-	var k, v interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
+	c.overflowRemove(key)
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
-		k, v = c.evictedKeys[0], c.evictedVals[0]
-		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
-	}
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
+	cb.invoke(c)
+	return
+}
+
+// AddMany adds the provided entries to the cache in order under a single
+// lock, as if added one by one by Add, and returns the number of
+// evictions that occurred. Callbacks run once the lock is released.
+func (c *Cache) AddMany(entries []simplelru.Entry) (evicted int) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	for _, e := range entries {
+		c.overflowRemove(e.Key)
+		if c.lru.Add(e.Key, e.Value) {
+			evicted++
+		}
+		c.publish(EventAdd, e.Key, e.Value)
+		c.shadowAdd(e.Key, e.Value)
 	}
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return evicted
+}
+
+// AddWithEviction adds a value to the cache like Add, also returning the
+// key and value of the entry evicted to make room, if any. The victim is
+// captured under the same lock as the add, so unlike an eviction callback
+// it is tied to this call.
+func (c *Cache) AddWithEviction(key, value interface{}) (evicted bool, evictedKey, evictedValue interface{}) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.overflowRemove(key)
+	evicted, evictedKey, evictedValue = c.lru.AddWithEviction(key, value)
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
 	return
 }
 
+// GetOrAdd looks up a key's value from the cache, updating its
+// "recently used"-ness, and adds the provided value if it is not contained.
+// Returns the value now cached for the key and whether it was already
+// contained.
+func (c *Cache) GetOrAdd(key, value interface{}) (actual interface{}, loaded bool) {
+	c.lock.Lock()
+	if actual, loaded = c.lru.Get(key); loaded {
+		c.shadowGet(key)
+		c.lock.Unlock()
+		return actual, true
+	}
+	c.shadowGet(key)
+	before := c.lru.Len()
+	c.overflowRemove(key)
+	c.lru.Add(key, value)
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return value, false
+}
+
+// GetOrCompute looks up a key's value from the cache, updating its
+// "recently used"-ness, and otherwise computes the value with fn and adds
+// it. Nothing is added if fn returns an error, which is returned as is.
+// fn runs outside of the lock, and concurrent lookups of the same missing
+// key share a single call of fn, the others waiting for its result, so fn
+// may call into the cache for other keys but not for the key itself.
+func (c *Cache) GetOrCompute(key interface{}, fn func(key interface{}) (interface{}, error)) (value interface{}, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	return c.flights.do(key, func() (interface{}, error) {
+		value, err := fn(key)
+		if err == nil {
+			c.Add(key, value)
+		}
+		return value, err
+	})
+}
+
 // Get looks up a key's value from the cache.
 func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
 	c.lock.Lock()
 	value, ok = c.lru.Get(key)
+	c.shadowGet(key)
+	if ok || c.overflow == nil || !c.overflow.promote {
+		c.lock.Unlock()
+		return value, ok
+	}
+	before := c.lru.Len()
+	value, ok = c.overflowPromote(key)
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
+	cb.invoke(c)
 	return value, ok
 }
 
+// GetMany looks up the provided keys in order under a single lock, as if
+// looked up one by one by Get, and returns the entries found and the keys
+// missing, both in the order of keys.
+func (c *Cache) GetMany(keys []interface{}) (found []simplelru.Entry, missing []interface{}) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	for _, key := range keys {
+		value, ok := c.lru.Get(key)
+		c.shadowGet(key)
+		if !ok && c.overflow != nil && c.overflow.promote {
+			value, ok = c.overflowPromote(key)
+		}
+		if ok {
+			found = append(found, simplelru.Entry{Key: key, Value: value})
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return found, missing
+}
+
+// SetFrozenRecency controls whether Get updates the "recently used"-ness
+// of keys. While frozen, Get behaves like Peek apart from counting lookups,
+// and entries are ordered by their last Add only.
+func (c *Cache) SetFrozenRecency(frozen bool) {
+	c.lock.Lock()
+	c.lru.SetFrozenRecency(frozen)
+	c.lock.Unlock()
+}
+
+// SetInsertPosition sets where Add places new keys in the recency order,
+// as a fraction of the length counted from the oldest entry. See
+// simplelru.LRU.SetInsertPosition.
+func (c *Cache) SetInsertPosition(frac float64) {
+	c.lock.Lock()
+	c.lru.SetInsertPosition(frac)
+	c.lock.Unlock()
+}
+
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 func (c *Cache) Contains(key interface{}) bool {
@@ -111,25 +340,33 @@ func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
 	return value, ok
 }
 
+// PeekWithRank returns the key value along with its position in the
+// eviction order, without updating the "recently used"-ness of the key.
+// Rank 0 is the oldest entry, the next one to be evicted.
+func (c *Cache) PeekWithRank(key interface{}) (value interface{}, rank int, ok bool) {
+	c.lock.RLock()
+	value, rank, ok = c.lru.PeekWithRank(key)
+	c.lock.RUnlock()
+	return value, rank, ok
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *Cache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
-	var k, v interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
 	if c.lru.Contains(key) {
 		c.lock.Unlock()
 		return true, false
 	}
+	c.overflowRemove(key)
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
-		k, v = c.evictedKeys[0], c.evictedVals[0]
-		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
-	}
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
-	}
+	cb.invoke(c)
 	return false, evicted
 }
 
@@ -137,75 +374,227 @@ func (c *Cache) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *Cache) PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool) {
-	var k, v interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
 	previous, ok = c.lru.Peek(key)
 	if ok {
 		c.lock.Unlock()
 		return previous, true, false
 	}
+	c.overflowRemove(key)
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
-		k, v = c.evictedKeys[0], c.evictedVals[0]
-		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return nil, false, evicted
+}
+
+// Increment atomically adds delta to the int64 value stored for key,
+// treating a missing key as zero, and updates the key's "recently
+// used"-ness. Returns the new value, or false if the key holds a value
+// that is not an int64.
+func (c *Cache) Increment(key interface{}, delta int64) (value int64, ok bool) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	if value, ok = c.lru.Increment(key, delta); ok {
+		c.overflowRemove(key)
+		c.publish(EventAdd, key, value)
+		c.shadowAdd(key, value)
 	}
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
+	cb.invoke(c)
+	return
+}
+
+// Swap atomically exchanges the values of two keys without updating their
+// "recently used"-ness. Returns false, leaving the cache unchanged,
+// unless both keys are contained.
+func (c *Cache) Swap(key1, key2 interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.lru.Swap(key1, key2) {
+		return false
 	}
-	return nil, false, evicted
+	v1, _ := c.lru.Peek(key1)
+	v2, _ := c.lru.Peek(key2)
+	c.publish(EventAdd, key1, v1)
+	c.publish(EventAdd, key2, v2)
+	return true
 }
 
-// Remove removes the provided key from the cache.
+// Remove removes the provided key from the cache, and from the overflow
+// cache if any. Returns whether it was contained in this cache.
 func (c *Cache) Remove(key interface{}) (present bool) {
-	var k, v interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
 	present = c.lru.Remove(key)
-	if c.onEvictedCB != nil && present {
-		k, v = c.evictedKeys[0], c.evictedVals[0]
-		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
+	c.removing = false
+	c.overflowRemove(key)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return
+}
+
+// RemoveMany removes the provided keys from the cache, and from the
+// overflow cache if any, under a single lock, returning the entries removed
+// from this cache. Keys not contained in the cache are skipped.
+func (c *Cache) RemoveMany(keys []interface{}) []simplelru.Entry {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
+	removed := c.lru.RemoveMany(keys)
+	c.removing = false
+	for _, key := range keys {
+		c.overflowRemove(key)
 	}
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && present {
-		c.onEvictedCB(k, v)
+	cb.invoke(c)
+	return removed
+}
+
+// RetainKeys removes every entry whose key is not among the provided keys,
+// returning the number of entries removed.
+func (c *Cache) RetainKeys(keys []interface{}) (removed int) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
+	removed = c.lru.RetainKeys(keys)
+	c.removing = false
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return removed
+}
+
+// RemoveMatch removes every entry whose key satisfies match, scanning the
+// keys once from oldest to newest, and returns the number removed. The
+// match function runs under the lock and must not call back into the cache.
+func (c *Cache) RemoveMatch(match func(key interface{}) bool) (removed int) {
+	c.lock.Lock()
+	c.removing = true
+	defer c.endRemove(c.lru.Len())
+	removed = c.lru.RemoveMatch(match)
+	if oc, ok := c.overflowCache().(interface {
+		RemoveMatch(func(key interface{}) bool) int
+	}); ok {
+		oc.RemoveMatch(match)
 	}
-	return
+	return removed
 }
 
-// Resize changes the cache size.
+// RemoveFunc removes every entry whose key and value satisfy match, such
+// as all the entries of a tenant, scanning the entries once from oldest to
+// newest under the lock, and returns the number removed. The match
+// function must not call back into the cache.
+func (c *Cache) RemoveFunc(match func(key, value interface{}) bool) (removed int) {
+	c.lock.Lock()
+	c.removing = true
+	defer c.endRemove(c.lru.Len())
+	removed = c.lru.RemoveFunc(match)
+	if oc, ok := c.overflowCache().(interface {
+		RemoveFunc(func(key, value interface{}) bool) int
+	}); ok {
+		oc.RemoveFunc(match)
+	}
+	return removed
+}
+
+// RemovePrefix removes every entry whose key is a string starting with
+// prefix, returning the number removed. Keys of other types are kept.
+func (c *Cache) RemovePrefix(prefix string) (removed int) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
+	removed = c.lru.RemovePrefix(prefix)
+	c.removing = false
+	if oc, ok := c.overflowCache().(interface {
+		RemovePrefix(string) int
+	}); ok {
+		oc.RemovePrefix(prefix)
+	}
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return removed
+}
+
+// Resize changes the cache size. If the cache was constructed with a
+// maximum size, larger sizes are clamped to it.
 func (c *Cache) Resize(size int) (evicted int) {
-	var ks, vs []interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
 	evicted = c.lru.Resize(size)
-	if c.onEvictedCB != nil && evicted > 0 {
-		ks, vs = c.evictedKeys, c.evictedVals
-		c.initEvictBuffers()
-	}
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted > 0 {
-		for i := 0; i < len(ks); i++ {
-			c.onEvictedCB(ks[i], vs[i])
-		}
-	}
+	cb.invoke(c)
 	return evicted
 }
 
+// ResizeChecked changes the cache size like Resize, but reports a size
+// above the maximum size: the cache is resized to the maximum size and
+// ErrMaxSize is returned, along with the size applied.
+func (c *Cache) ResizeChecked(size int) (newSize, evicted int, err error) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	newSize, evicted, err = c.lru.ResizeChecked(size)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return newSize, evicted, err
+}
+
+// ResizeToFill changes the cache size to ratio times the current number of
+// items, with a minimum size of 1. A ratio below 1 evicts the oldest items.
+func (c *Cache) ResizeToFill(ratio float64) (newSize, evicted int) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	newSize, evicted = c.lru.ResizeToFill(ratio)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return newSize, evicted
+}
+
 // RemoveOldest removes the oldest item from the cache.
 func (c *Cache) RemoveOldest() (key, value interface{}, ok bool) {
-	var k, v interface{}
 	c.lock.Lock()
+	before := c.lru.Len()
+	c.removing = true
 	key, value, ok = c.lru.RemoveOldest()
-	if c.onEvictedCB != nil && ok {
-		k, v = c.evictedKeys[0], c.evictedVals[0]
-		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
-	}
+	c.removing = false
+	cb := c.takeCallbacks(before)
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && ok {
-		c.onEvictedCB(k, v)
-	}
+	cb.invoke(c)
 	return
 }
 
+// DrainOldest removes up to n of the oldest entries from the cache without
+// invoking the eviction callbacks, returning them from oldest to newest.
+// Handlers registered with OnEvictKey and tags of the drained keys are
+// discarded.
+func (c *Cache) DrainOldest(n int) []simplelru.Entry {
+	c.lock.Lock()
+	before := c.lru.Len()
+	drained := c.lru.DrainOldest(n)
+	for _, e := range drained {
+		c.publish(EventRemove, e.Key, e.Value)
+		delete(c.keyHandlers, e.Key)
+		if c.tags != nil {
+			c.tags.remove(e.Key)
+		}
+	}
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return drained
+}
+
 // GetOldest returns the oldest entry
 func (c *Cache) GetOldest() (key, value interface{}, ok bool) {
 	c.lock.RLock()
@@ -214,6 +603,15 @@ func (c *Cache) GetOldest() (key, value interface{}, ok bool) {
 	return
 }
 
+// LeastValuable returns the entry with the lowest score without removing it
+// or updating its "recently used"-ness. Ties go to the oldest entry. The
+// score function runs under the lock and must not call back into the cache.
+func (c *Cache) LeastValuable(score func(key, value interface{}) int64) (key, value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.LeastValuable(score)
+}
+
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (c *Cache) Keys() []interface{} {
 	c.lock.RLock()
@@ -222,6 +620,50 @@ func (c *Cache) Keys() []interface{} {
 	return keys
 }
 
+// AppendKeys appends the keys in the cache, from oldest to newest, to dst
+// and returns the extended slice. It does not allocate if dst has enough
+// capacity.
+func (c *Cache) AppendKeys(dst []interface{}) []interface{} {
+	c.lock.RLock()
+	dst = c.lru.AppendKeys(dst)
+	c.lock.RUnlock()
+	return dst
+}
+
+// Items returns a consistent snapshot of the entries in the cache, from
+// oldest to newest. The returned slice is owned by the caller.
+func (c *Cache) Items() []simplelru.Entry {
+	c.lock.RLock()
+	items := c.lru.Items()
+	c.lock.RUnlock()
+	return items
+}
+
+// Range calls fn for each entry in the cache, from oldest to newest,
+// without updating the "recently used"-ness of the keys and without
+// copying the entries. It stops early if fn returns false. The read lock
+// is held throughout, so fn must not call back into the cache; use Items
+// to work on a snapshot instead.
+func (c *Cache) Range(fn func(key, value interface{}) bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	c.lru.Range(fn)
+}
+
+// MapValues replaces the value of every entry with the result of transform,
+// from oldest to newest, without updating the "recently used"-ness of the
+// keys. The transform runs under the lock and must not call back into the
+// cache. Each replaced value is published to subscribers as an EventAdd.
+func (c *Cache) MapValues(transform func(key, value interface{}) interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.MapValues(func(key, value interface{}) interface{} {
+		value = transform(key, value)
+		c.publish(EventAdd, key, value)
+		return value
+	})
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache) Len() int {
 	c.lock.RLock()
@@ -229,3 +671,176 @@ func (c *Cache) Len() int {
 	c.lock.RUnlock()
 	return length
 }
+
+// EnableCardinalityTracking starts recording every distinct key added to
+// the cache, as reported by CardinalitySeen. Keys are counted in a
+// fixed-size sketch, so memory does not grow with the number of keys.
+func (c *Cache) EnableCardinalityTracking() {
+	c.lock.Lock()
+	c.lru.EnableCardinalityTracking()
+	c.lock.Unlock()
+}
+
+// CardinalitySeen returns the estimated number of distinct keys added
+// since cardinality tracking was enabled, or 0 if it is disabled.
+func (c *Cache) CardinalitySeen() uint64 {
+	c.lock.RLock()
+	seen := c.lru.CardinalitySeen()
+	c.lock.RUnlock()
+	return seen
+}
+
+// OnEvictKey registers a handler invoked once, outside of the critical
+// section, when the provided key leaves the cache through an eviction,
+// removal or purge. It replaces any handler previously registered for the
+// key and runs after the cache-wide eviction callback. Returns false without
+// registering if the key is not contained; once the handler has fired, a
+// re-added key needs a new registration.
+func (c *Cache) OnEvictKey(key interface{}, fn func(value interface{})) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.lru.Contains(key) {
+		return false
+	}
+	if c.keyHandlers == nil {
+		c.keyHandlers = make(map[interface{}]func(value interface{}))
+	}
+	c.keyHandlers[key] = fn
+	return true
+}
+
+// OnEmpty registers a callback invoked when the cache becomes empty through
+// a removal, eviction or purge. It is invoked outside of the critical section
+// and replaces any previously registered callback. The callbacks registered
+// by OnEmpty and OnNonEmpty are invoked one at a time, in the order of the
+// changes, even across goroutines: a callback may thus be invoked by the
+// goroutine delivering an earlier one rather than by the goroutine that
+// made the change, after that goroutine's call returned.
+func (c *Cache) OnEmpty(fn func()) {
+	c.lock.Lock()
+	c.onEmpty = fn
+	c.lock.Unlock()
+}
+
+// OnNonEmpty registers a callback invoked when an empty cache receives its
+// first entry. It is invoked outside of the critical section and replaces
+// any previously registered callback.
+func (c *Cache) OnNonEmpty(fn func()) {
+	c.lock.Lock()
+	c.onNonEmpty = fn
+	c.lock.Unlock()
+}
+
+// SetLenWatermark registers a callback invoked with the current length,
+// outside of the critical section, when the number of items in the cache
+// rises to high or above. It is edge-triggered: it fires once on crossing
+// up and re-arms only after the length drops below high again, so it does
+// not fire if the cache is already at or above high when registered.
+// A nil callback removes the watermark.
+func (c *Cache) SetLenWatermark(high int, fn func(len int)) {
+	c.lock.Lock()
+	c.watermark = nil
+	if fn != nil {
+		c.watermark = &lenWatermark{
+			high:  high,
+			fn:    fn,
+			armed: c.lru.Len() < high,
+		}
+	}
+	c.lock.Unlock()
+}
+
+// EnableAccessCounts starts counting the hits of each entry, as reported
+// by TopAccessed. Counts are kept per entry and are dropped on eviction.
+func (c *Cache) EnableAccessCounts() {
+	c.lock.Lock()
+	c.lru.EnableAccessCounts()
+	c.lock.Unlock()
+}
+
+// EnableEntryMetadata starts recording when each entry is added and last
+// accessed, and counting its hits, as reported by PeekEntry. Recording
+// reads the clock on every Add and Get hit, and takes extra memory for
+// each entry added from then on.
+func (c *Cache) EnableEntryMetadata() {
+	c.lock.Lock()
+	c.lru.EnableEntryMetadata()
+	c.lock.Unlock()
+}
+
+// PeekEntry returns the entry of a key along with its metadata, without
+// updating the "recently used"-ness of the key. Times are zero unless
+// EnableEntryMetadata was called before the key was added, and hits are
+// only counted once it or EnableAccessCounts was called.
+func (c *Cache) PeekEntry(key interface{}) (info simplelru.EntryInfo, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.PeekEntry(key)
+}
+
+// ResetAccessCounts zeroes the hit count of every entry.
+func (c *Cache) ResetAccessCounts() {
+	c.lock.Lock()
+	c.lru.ResetAccessCounts()
+	c.lock.Unlock()
+}
+
+// TopAccessed returns up to n keys with the highest hit counts since access
+// counting was enabled or last reset, most accessed first.
+func (c *Cache) TopAccessed(n int) []simplelru.KeyCount {
+	c.lock.RLock()
+	counts := c.lru.TopAccessed(n)
+	c.lock.RUnlock()
+	return counts
+}
+
+// MarkDirty flags the entry of the provided key as having unflushed
+// modifications, returning if the key was contained. The flag survives
+// later adds of the same key until it is cleared or the entry is removed.
+func (c *Cache) MarkDirty(key interface{}) bool {
+	c.lock.Lock()
+	present := c.lru.MarkDirty(key)
+	c.lock.Unlock()
+	return present
+}
+
+// ClearDirty clears the dirty flag of the provided key, typically once its
+// value has been flushed, returning if the entry was dirty.
+func (c *Cache) ClearDirty(key interface{}) bool {
+	c.lock.Lock()
+	wasDirty := c.lru.ClearDirty(key)
+	c.lock.Unlock()
+	return wasDirty
+}
+
+// DirtyKeys returns a slice of the keys flagged dirty, from oldest to newest.
+func (c *Cache) DirtyKeys() []interface{} {
+	c.lock.RLock()
+	keys := c.lru.DirtyKeys()
+	c.lock.RUnlock()
+	return keys
+}
+
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity.
+func (c *Cache) Stats() simplelru.Stats {
+	c.lock.RLock()
+	st := c.lru.Stats()
+	c.lock.RUnlock()
+	return st
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *Cache) ResetStats() {
+	c.lock.Lock()
+	c.lru.ResetStats()
+	c.lock.Unlock()
+}
+
+// Check verifies the internal consistency of the cache, returning an error
+// describing the first violation found. See simplelru.LRU.Check.
+func (c *Cache) Check() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lru.Check()
+}
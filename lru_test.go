@@ -1,8 +1,13 @@
 package lru
 
 import (
+	"context"
+	"errors"
 	"math/rand"
+	"sync"
 	"testing"
+
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 func BenchmarkLRU_Rand(b *testing.B) {
@@ -291,3 +296,711 @@ func TestLRUResize(t *testing.T) {
 		t.Errorf("Cache should have contained 2 elements")
 	}
 }
+
+// test that ResizeChecked reports sizes above the maximum size
+func TestLRUResizeChecked(t *testing.T) {
+	onEvictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		onEvictCounter++
+	}
+	l, err := NewWithMax(2, 4, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+
+	size, evicted, err := l.ResizeChecked(10)
+	if size != 4 || evicted != 0 || err != ErrMaxSize {
+		t.Fatalf("bad: %v %v %v", size, evicted, err)
+	}
+	size, evicted, err = l.ResizeChecked(1)
+	if size != 1 || evicted != 1 || err != nil {
+		t.Fatalf("bad: %v %v %v", size, evicted, err)
+	}
+	if onEvictCounter != 1 {
+		t.Errorf("onEvicted should have been called 1 time: %v", onEvictCounter)
+	}
+}
+
+// test that ResizeToFill fires the eviction callback
+func TestLRUResizeToFill(t *testing.T) {
+	onEvictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		onEvictCounter++
+	}
+	l, err := NewWithEvict(10, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+
+	size, evicted := l.ResizeToFill(0.5)
+	if size != 2 || evicted != 2 {
+		t.Errorf("bad: %v %v", size, evicted)
+	}
+	if onEvictCounter != 2 {
+		t.Errorf("onEvicted should have been called 2 times: %v", onEvictCounter)
+	}
+}
+
+// test that OnEmpty and OnNonEmpty fire only on transitions
+func TestLRUEmptinessCallbacks(t *testing.T) {
+	empty, nonEmpty := 0, 0
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.OnEmpty(func() { empty++ })
+	l.OnNonEmpty(func() { nonEmpty++ })
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if empty != 0 || nonEmpty != 1 {
+		t.Fatalf("bad transitions: %v %v", empty, nonEmpty)
+	}
+
+	l.Remove(2)
+	l.Remove(3)
+	if empty != 1 || nonEmpty != 1 {
+		t.Fatalf("bad transitions: %v %v", empty, nonEmpty)
+	}
+
+	l.Remove(3)
+	l.Purge()
+	if empty != 1 {
+		t.Fatalf("should not fire without a transition: %v", empty)
+	}
+
+	l.ContainsOrAdd(4, 4)
+	l.Purge()
+	if empty != 2 || nonEmpty != 2 {
+		t.Fatalf("bad transitions: %v %v", empty, nonEmpty)
+	}
+}
+
+// test that emptiness callbacks are delivered in order across goroutines
+func TestLRUEmptinessCallbacksOrder(t *testing.T) {
+	l, err := New(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var lock sync.Mutex
+	active, bad := false, 0
+	l.OnNonEmpty(func() {
+		lock.Lock()
+		if active {
+			bad++
+		}
+		active = true
+		lock.Unlock()
+	})
+	l.OnEmpty(func() {
+		lock.Lock()
+		if !active {
+			bad++
+		}
+		active = false
+		lock.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Add(1, 1)
+				l.Remove(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if bad != 0 || active != (l.Len() > 0) {
+		t.Fatalf("out of order transitions: %v %v", bad, active)
+	}
+
+	// A callback may change the cache again
+	l.Purge()
+	nonEmpty := 0
+	l.OnEmpty(func() { l.Add(2, 2) })
+	l.OnNonEmpty(func() { nonEmpty++ })
+	l.Add(1, 1)
+	l.Remove(1)
+	if !l.Contains(2) || nonEmpty != 2 {
+		t.Fatalf("callback should have added 2: %v", nonEmpty)
+	}
+}
+
+// test that RemoveMany fires the eviction callback for each removed key
+func TestLRURemoveMany(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvict(4, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+
+	removed := l.RemoveMany([]interface{}{2, 0, 7})
+	if len(removed) != 2 || removed[0].Key != 2 || removed[1].Key != 0 {
+		t.Errorf("bad removed entries: %v", removed)
+	}
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 0 {
+		t.Errorf("bad evicted keys: %v", evicted)
+	}
+	if l.Len() != 2 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+}
+
+// test that AddMany and GetMany behave like repeated Add and Get
+func TestLRUAddGetMany(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvict(3, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	n := l.AddMany([]simplelru.Entry{{Key: 0, Value: 0}, {Key: 1, Value: 1}, {Key: 2, Value: 2}, {Key: 3, Value: 3}})
+	if n != 1 || len(evicted) != 1 || evicted[0] != 0 {
+		t.Fatalf("bad evictions: %v %v", n, evicted)
+	}
+
+	found, missing := l.GetMany([]interface{}{1, 0, 3, 4})
+	if len(found) != 2 || found[0].Key != 1 || found[0].Value != 1 || found[1].Key != 3 {
+		t.Fatalf("bad found entries: %v", found)
+	}
+	if len(missing) != 2 || missing[0] != 0 || missing[1] != 4 {
+		t.Fatalf("bad missing keys: %v", missing)
+	}
+	// The lookups updated recency, 2 is the oldest
+	if k, _, _ := l.GetOldest(); k != 2 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+	if st := l.Stats(); st.Hits != 2 || st.Misses != 2 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+// test that RemoveFunc matches on values and fires the eviction callback
+func TestLRURemoveFunc(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvict(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i%3)
+	}
+
+	removed := l.RemoveFunc(func(k, v interface{}) bool {
+		return v == 1
+	})
+	if removed != 3 || l.Len() != 5 {
+		t.Fatalf("bad: %v %v", removed, l.Len())
+	}
+	if len(evicted) != 3 || evicted[0] != 1 || evicted[1] != 4 || evicted[2] != 7 {
+		t.Fatalf("bad evicted keys: %v", evicted)
+	}
+
+	// A panic in match keeps the removals made so far and their callbacks
+	evicted = nil
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad panic: %v", r)
+			}
+		}()
+		l.RemoveFunc(func(k, v interface{}) bool {
+			if k == 5 {
+				panic("boom")
+			}
+			return v == 0
+		})
+	}()
+	if len(evicted) != 2 || evicted[0] != 0 || evicted[1] != 3 {
+		t.Fatalf("bad evicted keys: %v", evicted)
+	}
+	l.RemoveMatch(func(k interface{}) bool {
+		return k == 2
+	})
+	if len(evicted) != 3 || evicted[2] != 2 || l.Len() != 2 {
+		t.Fatalf("bad evicted keys: %v", evicted)
+	}
+}
+
+// test that RetainKeys fires the eviction callback for each removed key
+func TestLRURetainKeys(t *testing.T) {
+	onEvictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		onEvictCounter++
+	}
+	l, err := NewWithEvict(4, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+
+	if removed := l.RetainKeys([]interface{}{3}); removed != 3 {
+		t.Errorf("3 elements should have been removed: %v", removed)
+	}
+	if onEvictCounter != 3 {
+		t.Errorf("onEvicted should have been called 3 times: %v", onEvictCounter)
+	}
+	if !l.Contains(3) || l.Len() != 1 {
+		t.Errorf("only 3 should have been retained")
+	}
+}
+
+// test that Purge and PurgeReverse fire callbacks in opposite orders
+func TestLRUPurgeOrder(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvict(3, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Add(i, i)
+	}
+	l.Purge()
+	for i := 0; i < 3; i++ {
+		l.Add(i, i)
+	}
+	l.PurgeReverse()
+
+	expected := []interface{}{0, 1, 2, 2, 1, 0}
+	if len(evicted) != len(expected) {
+		t.Fatalf("bad evict count: %v", len(evicted))
+	}
+	for i, k := range expected {
+		if evicted[i] != k {
+			t.Fatalf("bad evict order: %v", evicted)
+		}
+	}
+}
+
+// test that OnEvictKey handlers fire once for their key only
+func TestLRUOnEvictKey(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvict(2, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l.OnEvictKey(1, func(interface{}) {}) {
+		t.Fatalf("should not register a handler for a missing key")
+	}
+
+	var fired []interface{}
+	l.Add(1, 10)
+	l.Add(2, 20)
+	l.OnEvictKey(1, func(v interface{}) {
+		if len(evicted) == 0 {
+			t.Errorf("handler should run after the eviction callback")
+		}
+		fired = append(fired, v)
+	})
+	l.Add(3, 30)
+	if len(fired) != 1 || fired[0] != 10 {
+		t.Fatalf("bad handler calls: %v", fired)
+	}
+
+	l.Add(1, 11)
+	l.Remove(1)
+	if len(fired) != 1 {
+		t.Fatalf("handler should only fire once: %v", fired)
+	}
+
+	l.OnEvictKey(3, func(v interface{}) { fired = append(fired, v) })
+	l.Purge()
+	if len(fired) != 2 || fired[1] != 30 {
+		t.Fatalf("bad handler calls: %v", fired)
+	}
+}
+
+// test that PurgeCtx stops once the context is cancelled
+func TestLRUPurgeCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+		if len(evicted) == 3 {
+			cancel()
+		}
+	}
+	l, err := NewWithEvict(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+
+	purged, err := l.PurgeCtx(ctx)
+	if purged != 3 || err != context.Canceled {
+		t.Fatalf("bad: %v %v", purged, err)
+	}
+	if l.Len() != 5 || l.Contains(2) || !l.Contains(3) {
+		t.Fatalf("oldest entries should have been purged: %v", l.Keys())
+	}
+
+	purged, err = l.PurgeCtx(context.Background())
+	if purged != 5 || err != nil || l.Len() != 0 {
+		t.Fatalf("bad: %v %v %v", purged, err, l.Len())
+	}
+}
+
+// test that the length watermark is edge-triggered
+func TestLRULenWatermark(t *testing.T) {
+	var fired []int
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetLenWatermark(3, func(n int) { fired = append(fired, n) })
+
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	if len(fired) != 1 || fired[0] != 3 {
+		t.Fatalf("should fire once on crossing up: %v", fired)
+	}
+
+	l.Remove(5)
+	l.Add(5, 5)
+	if len(fired) != 1 {
+		t.Fatalf("should not fire while at or above the watermark: %v", fired)
+	}
+
+	l.RemoveMany([]interface{}{4, 5})
+	l.Add(6, 6)
+	if len(fired) != 2 || fired[1] != 3 {
+		t.Fatalf("should fire again after re-arming: %v", fired)
+	}
+
+	l.SetLenWatermark(3, nil)
+	l.Purge()
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	if len(fired) != 2 {
+		t.Fatalf("should not fire once removed: %v", fired)
+	}
+}
+
+// test that GetOrAdd only adds missing keys
+func TestLRUGetOrAdd(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, loaded := l.GetOrAdd(1, 1); loaded || v != 1 {
+		t.Errorf("bad: %v %v", v, loaded)
+	}
+	l.Add(2, 2)
+	if v, loaded := l.GetOrAdd(1, 10); !loaded || v != 1 {
+		t.Errorf("bad: %v %v", v, loaded)
+	}
+	l.Add(3, 3)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Errorf("GetOrAdd should have updated recent-ness of 1")
+	}
+}
+
+// test that safe callbacks recover from panics and keep running the rest
+func TestLRUSafeCallbacks(t *testing.T) {
+	var evicted []interface{}
+	l, err := NewWithEvict(1, func(k, v interface{}) {
+		evicted = append(evicted, k)
+		if k == 1 {
+			panic("boom")
+		}
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var recovered []interface{}
+	l.EnableSafeCallbacks(func(r interface{}) {
+		recovered = append(recovered, r)
+	})
+	emptied := false
+	l.OnEmpty(func() { emptied = true })
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if len(recovered) != 1 || recovered[0] != "boom" {
+		t.Fatalf("bad recovered: %v", recovered)
+	}
+	if !l.Contains(2) || l.Len() != 1 {
+		t.Fatalf("bad cache state after panic")
+	}
+	l.Remove(2)
+	if !emptied {
+		t.Fatalf("OnEmpty should still run")
+	}
+	if len(evicted) != 2 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+
+	// Without safe callbacks the panic reaches the caller.
+	l2, _ := NewWithEvict(1, func(k, v interface{}) { panic("boom") })
+	l2.Add(1, 1)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad recover: %v", r)
+			}
+		}()
+		l2.Add(2, 2)
+	}()
+	if !l2.Contains(2) {
+		t.Fatalf("bad cache state after panic")
+	}
+}
+
+// test that Increment is atomic under concurrent use
+func TestLRUIncrement(t *testing.T) {
+	l, err := New(8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Increment("hits", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := l.Get("hits"); v != int64(1000) {
+		t.Fatalf("bad value: %v", v)
+	}
+}
+
+// test that AddWithEviction reports the victim synchronously
+func TestLRUAddWithEviction(t *testing.T) {
+	var cbKey interface{}
+	l, err := NewWithEvict(1, func(k, v interface{}) { cbKey = k })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	evicted, k, v := l.AddWithEviction(2, 2)
+	if !evicted || k != 1 || v != 1 {
+		t.Fatalf("bad: %v %v %v", evicted, k, v)
+	}
+	if cbKey != 1 {
+		t.Fatalf("callback should still fire: %v", cbKey)
+	}
+}
+
+// testGetOrCompute checks that GetOrCompute only computes missing keys,
+// caches nothing on error and does not hold the lock while computing.
+func testGetOrCompute(t *testing.T, getOrCompute func(key interface{}, fn func(key interface{}) (interface{}, error)) (interface{}, error)) {
+	calls := 0
+	square := func(k interface{}) (interface{}, error) {
+		calls++
+		return k.(int) * k.(int), nil
+	}
+	if v, err := getOrCompute(3, square); err != nil || v != 9 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, err := getOrCompute(3, square); err != nil || v != 9 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if calls != 1 {
+		t.Fatalf("should compute once: %v", calls)
+	}
+
+	errBoom := errors.New("boom")
+	fail := func(k interface{}) (interface{}, error) {
+		return nil, errBoom
+	}
+	if v, err := getOrCompute(4, fail); err != errBoom || v != nil {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, err := getOrCompute(4, square); err != nil || v != 16 {
+		t.Fatalf("errors should not be cached: %v %v", v, err)
+	}
+
+	// fn runs outside of the lock, so it may look up other keys
+	nested := func(k interface{}) (interface{}, error) {
+		return getOrCompute(k.(int)-1, square)
+	}
+	if v, err := getOrCompute(6, nested); err != nil || v != 25 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+
+	// A panic in fn leaves the cache usable
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad panic: %v", r)
+			}
+		}()
+		getOrCompute(7, func(k interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	}()
+	if v, err := getOrCompute(7, square); err != nil || v != 49 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+}
+
+// test that GetOrCompute only computes missing keys
+func TestLRUGetOrCompute(t *testing.T) {
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testGetOrCompute(t, l.GetOrCompute)
+}
+
+// test that ResetStats zeroes the counters
+func TestLRUResetStats(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+	l.Get(2)
+	if st := l.Stats(); st.Adds != 1 || st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+	l.ResetStats()
+	if st := l.Stats(); st != (simplelru.Stats{Len: 1, Cap: 2}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+// test that Range visits every entry under the read lock
+func TestLRURange(t *testing.T) {
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	sum := 0
+	l.Range(func(k, v interface{}) bool {
+		sum += v.(int)
+		return true
+	})
+	if sum != 6 {
+		t.Fatalf("bad sum: %v", sum)
+	}
+
+	// A panic in fn does not leave the read lock held
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad panic: %v", r)
+			}
+		}()
+		l.Range(func(k, v interface{}) bool {
+			panic("boom")
+		})
+	}()
+	l.Add(4, 4)
+	if l.Len() != 4 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// test that PeekEntry reports entry metadata once enabled
+func TestLRUPeekEntry(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.EnableEntryMetadata()
+	l.Add(1, 1)
+	l.Get(1)
+	info, ok := l.PeekEntry(1)
+	if !ok || info.Value != 1 || info.Hits != 1 || info.Added.IsZero() || info.Accessed.Before(info.Added) {
+		t.Fatalf("bad entry: %+v", info)
+	}
+	if _, ok := l.PeekEntry(2); ok {
+		t.Fatalf("should not contain 2")
+	}
+}
+
+// test that a panic in MapValues does not leave the cache locked
+func TestLRUMapValuesPanic(t *testing.T) {
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad panic: %v", r)
+			}
+		}()
+		l.MapValues(func(k, v interface{}) interface{} {
+			panic("boom")
+		})
+	}()
+	l.MapValues(func(k, v interface{}) interface{} {
+		return v.(int) * 10
+	})
+	if v, ok := l.Get(1); !ok || v != 10 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+// test that a panic in the LeastValuable score does not leave the cache locked
+func TestLRULeastValuablePanic(t *testing.T) {
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("bad panic: %v", r)
+			}
+		}()
+		l.LeastValuable(func(k, v interface{}) int64 {
+			panic("boom")
+		})
+	}()
+	l.Add(3, 3)
+	k, _, ok := l.LeastValuable(func(k, v interface{}) int64 {
+		return -int64(v.(int))
+	})
+	if !ok || k != 3 {
+		t.Fatalf("bad: %v %v", k, ok)
+	}
+}
@@ -0,0 +1,227 @@
+package lru
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// LRUKCache is a thread-safe fixed size LRU-K cache. It evicts the entry
+// whose K-th most recent access is the oldest, entries accessed fewer than
+// K times going first, from the least recently used. Entries used once
+// then, as in a scan, do not evict entries used repeatedly, which suits
+// database buffer style workloads. LRU-1 is LRU; K of 2 is the common
+// choice. Operations take logarithmic time, and the access history of an
+// entry is forgotten when it is evicted.
+type LRUKCache struct {
+	size  int
+	k     int
+	items map[interface{}]*lrukEntry
+	heap  lrukHeap
+	now   uint64
+	lock  sync.Mutex
+}
+
+// lrukEntry is an entry of an LRUKCache with its last K access times.
+type lrukEntry struct {
+	key, value interface{}
+	hist       []uint64 // ring of access times
+	refs       int      // number of accesses, up to K
+	next       int      // next position in hist
+	index      int      // position in the heap
+}
+
+// kth returns the K-th most recent access time, and whether there is one.
+func (e *lrukEntry) kth() (uint64, bool) {
+	return e.hist[e.next], e.refs == len(e.hist)
+}
+
+// last returns the most recent access time.
+func (e *lrukEntry) last() uint64 {
+	return e.hist[(e.next+len(e.hist)-1)%len(e.hist)]
+}
+
+// lrukHeap orders entries from the next one to evict.
+type lrukHeap []*lrukEntry
+
+func (h lrukHeap) Len() int { return len(h) }
+
+func (h lrukHeap) Less(i, j int) bool {
+	ki, fulli := h[i].kth()
+	kj, fullj := h[j].kth()
+	if fulli != fullj {
+		return !fulli
+	}
+	if !fulli {
+		return h[i].last() < h[j].last()
+	}
+	return ki < kj
+}
+
+func (h lrukHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lrukHeap) Push(x interface{}) {
+	e := x.(*lrukEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lrukHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// NewLRUK creates an LRUKCache of the given size, tracking the last k
+// accesses of each entry.
+func NewLRUK(size, k int) (*LRUKCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	if k <= 0 {
+		return nil, fmt.Errorf("invalid k")
+	}
+	c := &LRUKCache{
+		size:  size,
+		k:     k,
+		items: make(map[interface{}]*lrukEntry),
+	}
+	return c, nil
+}
+
+// touch records an access to an entry in the heap.
+func (c *LRUKCache) touch(e *lrukEntry) {
+	c.now++
+	e.hist[e.next] = c.now
+	e.next = (e.next + 1) % c.k
+	if e.refs < c.k {
+		e.refs++
+	}
+}
+
+// Get looks up a key's value from the cache, recording the access.
+func (c *LRUKCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.touch(e)
+	heap.Fix(&c.heap, e.index)
+	return e.value, true
+}
+
+// Add adds a value to the cache, recording an access. Returns true if an
+// eviction occurred.
+func (c *LRUKCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.value = value
+		c.touch(e)
+		heap.Fix(&c.heap, e.index)
+		return false
+	}
+
+	if len(c.items) >= c.size {
+		c.evict()
+		evicted = true
+	}
+	e := &lrukEntry{key: key, value: value, hist: make([]uint64, c.k)}
+	c.touch(e)
+	heap.Push(&c.heap, e)
+	c.items[key] = e
+	return evicted
+}
+
+// evict removes the entry with the oldest K-th most recent access.
+func (c *LRUKCache) evict() {
+	victim := heap.Pop(&c.heap).(*lrukEntry)
+	delete(c.items, victim.key)
+}
+
+// Peek returns the key value (or undefined if not found) without recording
+// an access.
+func (c *LRUKCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		return e.value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without recording an access.
+func (c *LRUKCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *LRUKCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	heap.Remove(&c.heap, e.index)
+	delete(c.items, key)
+	return true
+}
+
+// Keys returns a slice of the keys in the cache, in eviction order. It
+// takes O(n log n) time.
+func (c *LRUKCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sorted := append(lrukHeap(nil), c.heap...)
+	sort.Slice(sorted, sorted.Less)
+	keys := make([]interface{}, len(sorted))
+	for i, e := range sorted {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *LRUKCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.items)
+}
+
+// Resize changes the cache size, returning the number of entries evicted.
+// Sizes below 1 are raised to 1.
+func (c *LRUKCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = size
+	for len(c.items) > size {
+		c.evict()
+		evicted++
+	}
+	return evicted
+}
+
+// Purge is used to completely clear the cache.
+func (c *LRUKCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[interface{}]*lrukEntry)
+	c.heap = nil
+}
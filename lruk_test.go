@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkLRUK_Rand(b *testing.B) {
+	l, err := NewLRUK(8192, 2)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestLRUK(t *testing.T) {
+	l, err := NewLRUK(3, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+	l.Get(2)
+	l.Get(1)
+	// 3 was accessed once, then 2 has the oldest second to last access
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{3, 2, 1}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// A scan of keys used once only evicts 3 then themselves
+	for i := 10; i < 20; i++ {
+		if !l.Add(i, i) {
+			t.Fatalf("should evict")
+		}
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{19, 2, 1}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	if v, ok := l.Peek(2); !ok || v != 2 || !l.Contains(2) {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if !l.Remove(2) || l.Remove(2) || l.Len() != 2 {
+		t.Fatalf("bad remove")
+	}
+	l.Purge()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad purge")
+	}
+	if _, err := NewLRUK(3, 0); err == nil {
+		t.Fatalf("should reject k")
+	}
+	if _, err := NewLRUK(0, 2); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
+
+// Test that LRU-1 behaves like LRU
+func TestLRUK_One(t *testing.T) {
+	l, err := NewLRUK(2, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(1)
+	l.Add(3, 3)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{1, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
@@ -0,0 +1,18 @@
+package lru
+
+// Memoize returns a function that caches the results of fn in an LRU of the
+// given size, computing them on a miss. Concurrent calls for the same key
+// that miss share a single invocation of fn. If fn panics, the call that
+// invoked it panics, and the others sharing the invocation return nil.
+func Memoize(size int, fn func(key interface{}) interface{}) (func(key interface{}) interface{}, error) {
+	cache, err := NewLoading(size, func(key interface{}) (interface{}, error) {
+		return fn(key), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(key interface{}) interface{} {
+		value, _ := cache.Get(key)
+		return value
+	}, nil
+}
@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	square, err := Memoize(2, func(key interface{}) interface{} {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return key.(int) * key.(int)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	call := func() {
+		defer wg.Done()
+		if v := square(3); v != 9 {
+			t.Errorf("bad value: %v", v)
+		}
+	}
+	wg.Add(1)
+	go call()
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go call()
+	}
+	close(release)
+	wg.Wait()
+
+	if v := square(3); v != 9 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("bad call count: %v", n)
+	}
+
+	square(4)
+	square(5)
+	square(3)
+	if atomic.LoadInt32(&calls) != 4 {
+		t.Fatalf("3 should have been evicted and recomputed")
+	}
+
+	if _, err := Memoize(0, nil); err == nil {
+		t.Fatalf("should reject zero size")
+	}
+}
@@ -0,0 +1,83 @@
+package lru
+
+// OverflowCache receives the entries a Cache evicts to make room for new
+// ones. Interface implements it, as do the caches of this package other
+// than TwoQueueCache and ARCCache, which are adapted by AsInterface.
+type OverflowCache interface {
+	Add(key, value interface{}) (evicted bool)
+	Peek(key interface{}) (value interface{}, ok bool)
+	Remove(key interface{}) (present bool)
+}
+
+// overflow is the victim cache of a Cache.
+type overflow struct {
+	cache   OverflowCache
+	promote bool
+}
+
+// SetOverflow adds every entry subsequently evicted from the cache for
+// capacity, including by Resize, to the provided overflow cache instead of
+// discarding it. Entries removed explicitly, by Remove, Purge and the like,
+// are not added. Eviction callbacks and events still fire for entries
+// moved to the overflow. A nil overflow stops moving entries.
+//
+// If promote is true, a Get missing the cache looks the key up in the
+// overflow and, if found, moves the entry back into the cache, possibly
+// evicting another entry into the overflow. The lookup still counts as a
+// miss in Stats. Each cache keeps its own capacity, and entries evicted
+// from the overflow are handled by its own policy.
+//
+// An entry is held by at most one of them: adding a key to this cache
+// removes it from the overflow, and so do Remove, RemoveMany and
+// InvalidateTag. RemoveMatch, RemoveFunc and RemovePrefix apply to the
+// overflow too if it has a method of the same name, as Cache does. Purge
+// and RetainKeys only apply to this cache.
+//
+// The overflow cache is called with the lock of this cache held, so it
+// must not call back into this cache, directly or by overflowing into it.
+func (c *Cache) SetOverflow(oc OverflowCache, promote bool) {
+	c.lock.Lock()
+	c.overflow = nil
+	if oc != nil {
+		c.overflow = &overflow{cache: oc, promote: promote}
+	}
+	c.lock.Unlock()
+}
+
+// overflowAdd moves an evicted entry to the overflow cache, if any.
+// It must be called with the lock held.
+func (c *Cache) overflowAdd(key, value interface{}) {
+	if c.overflow != nil {
+		c.overflow.cache.Add(key, value)
+	}
+}
+
+// overflowRemove removes a key from the overflow cache, if any, so that a
+// stale copy cannot be promoted back. It must be called with the lock held.
+func (c *Cache) overflowRemove(key interface{}) {
+	if c.overflow != nil {
+		c.overflow.cache.Remove(key)
+	}
+}
+
+// overflowCache returns the overflow cache, or nil if there is none.
+// It must be called with the lock held.
+func (c *Cache) overflowCache() OverflowCache {
+	if c.overflow == nil {
+		return nil
+	}
+	return c.overflow.cache
+}
+
+// overflowPromote moves an entry from the overflow cache back into the
+// cache. It must be called with the lock held.
+func (c *Cache) overflowPromote(key interface{}) (value interface{}, ok bool) {
+	if value, ok = c.overflow.cache.Peek(key); !ok {
+		return nil, false
+	}
+	c.overflow.cache.Remove(key)
+	c.lru.Add(key, value)
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	return value, true
+}
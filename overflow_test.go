@@ -0,0 +1,147 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+func TestCacheOverflow(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	next, err := NewByPolicy("arc", 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetOverflow(next, false)
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if v, ok := next.Peek(1); !ok || v != 1 {
+		t.Fatalf("evicted entry should overflow: %v %v", v, ok)
+	}
+	l.Remove(2)
+	if next.Contains(2) {
+		t.Fatalf("removed entry should not overflow")
+	}
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("should not promote")
+	}
+
+	l.SetOverflow(next, true)
+	l.Add(4, 4)
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("should promote: %v %v", v, ok)
+	}
+	if next.Contains(1) {
+		t.Fatalf("promoted entry should leave the overflow")
+	}
+	// Promoting 1 evicted 3 into the overflow
+	if l.Contains(3) || !next.Contains(3) {
+		t.Fatalf("bad overflow after promotion")
+	}
+	if st := l.Stats(); st.Hits != 0 || st.Misses != 2 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	l.SetOverflow(nil, false)
+	l.Add(5, 5)
+	if next.Contains(4) {
+		t.Fatalf("should stop overflowing")
+	}
+}
+
+// Test that adding a key drops its stale copy from the overflow
+func TestCacheOverflow_Add(t *testing.T) {
+	l, err := New(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	next, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetOverflow(next, true)
+
+	l.Add("k", "v1")
+	l.Add("x", "x")
+	if !next.Contains("k") {
+		t.Fatalf("k should overflow")
+	}
+	l.Add("k", "v2")
+	if next.Contains("k") {
+		t.Fatalf("adding k should drop it from the overflow")
+	}
+	l.Remove("k")
+	if v, ok := l.Get("k"); ok {
+		t.Fatalf("stale value promoted: %v", v)
+	}
+
+	// The other add paths too
+	l.Add("y", "y")
+	l.GetOrAdd("x", "x2")
+	if next.Contains("x") {
+		t.Fatalf("GetOrAdd should drop x from the overflow")
+	}
+	l.ContainsOrAdd("y", "y2")
+	if next.Contains("y") {
+		t.Fatalf("ContainsOrAdd should drop y from the overflow")
+	}
+	l.AddMany([]simplelru.Entry{{Key: "x", Value: "x3"}})
+	if next.Contains("x") {
+		t.Fatalf("AddMany should drop x from the overflow")
+	}
+}
+
+// Test that explicit removals reach the overflow
+func TestCacheOverflow_Remove(t *testing.T) {
+	l, err := New(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	next, err := New(8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetOverflow(next, true)
+
+	l.Add("a", 1)
+	l.Add("b", 2)
+	if present := l.Remove("a"); present {
+		t.Fatalf("a is only in the overflow")
+	}
+	if _, ok := l.Get("a"); ok {
+		t.Fatalf("removed entry promoted")
+	}
+
+	l.Add("p/1", 1)
+	l.Add("p/2", 2)
+	l.Add("c", 3)
+	if n := l.RemovePrefix("p/"); n != 0 {
+		t.Fatalf("bad removed: %v", n)
+	}
+	if next.Contains("p/1") || next.Contains("p/2") {
+		t.Fatalf("RemovePrefix should apply to the overflow")
+	}
+
+	l.AddWithTags("t1", 1, "tag")
+	l.AddWithTags("t2", 2, "tag")
+	if !next.Contains("t1") {
+		t.Fatalf("t1 should overflow")
+	}
+	if tags := l.Tags("t1"); len(tags) != 1 || tags[0] != "tag" {
+		t.Fatalf("tags should follow the entry: %v", tags)
+	}
+	if n := l.InvalidateTag("tag"); n != 1 {
+		t.Fatalf("bad removed: %v", n)
+	}
+	if next.Contains("t1") {
+		t.Fatalf("InvalidateTag should apply to the overflow")
+	}
+	if _, ok := l.Get("t1"); ok {
+		t.Fatalf("invalidated entry promoted")
+	}
+}
@@ -0,0 +1,78 @@
+package lru
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PolicyFunc creates a cache of the given size for a policy.
+type PolicyFunc func(size int) (Interface, error)
+
+var (
+	policiesLock sync.RWMutex
+	policies     = map[string]PolicyFunc{
+		"lru": func(size int) (Interface, error) { return New(size) },
+		"2q": func(size int) (Interface, error) {
+			c, err := New2Q(size)
+			if err != nil {
+				return nil, err
+			}
+			return c.AsInterface(), nil
+		},
+		"arc": func(size int) (Interface, error) {
+			c, err := NewARC(size)
+			if err != nil {
+				return nil, err
+			}
+			return c.AsInterface(), nil
+		},
+		"lfu":     func(size int) (Interface, error) { return NewLFU(size) },
+		"slru":    func(size int) (Interface, error) { return NewSLRU(size) },
+		"tinylfu": func(size int) (Interface, error) { return NewTinyLFU(size) },
+		"clock":   func(size int) (Interface, error) { return NewClock(size) },
+		"sieve":   func(size int) (Interface, error) { return NewSieve(size) },
+		"s3fifo":  func(size int) (Interface, error) { return NewS3FIFO(size) },
+		"lru-2":   func(size int) (Interface, error) { return NewLRUK(size, 2) },
+	}
+)
+
+// NewByPolicy creates a cache of the given size using the named eviction
+// policy, so that services can pick it from configuration. The built-in
+// policies are "lru", "2q", "arc", "lfu", "slru", "tinylfu", "clock",
+// "sieve", "s3fifo" and "lru-2", using the default parameters of each
+// cache. Names are case insensitive. Other policies, or built-in ones with
+// other parameters, can be added by RegisterPolicy.
+func NewByPolicy(policy string, size int) (Interface, error) {
+	policiesLock.RLock()
+	fn, ok := policies[strings.ToLower(policy)]
+	policiesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown policy %q, expected one of %s",
+			policy, strings.Join(Policies(), ", "))
+	}
+	return fn(size)
+}
+
+// RegisterPolicy makes a policy available to NewByPolicy under the given
+// name, replacing any policy registered under the same name, built-in
+// ones included.
+func RegisterPolicy(name string, fn PolicyFunc) {
+	policiesLock.Lock()
+	policies[strings.ToLower(name)] = fn
+	policiesLock.Unlock()
+}
+
+// Policies returns the sorted names of the policies available to
+// NewByPolicy.
+func Policies() []string {
+	policiesLock.RLock()
+	defer policiesLock.RUnlock()
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,75 @@
+package lru
+
+import "time"
+
+const (
+	// MaxEvictionRateWindow is the longest window EvictionRate can report on
+	MaxEvictionRateWindow = 60 * time.Second
+)
+
+// rateBuckets is the number of one-second buckets kept by an evictionRate
+const rateBuckets = int(MaxEvictionRateWindow / time.Second)
+
+// evictionRate counts evictions in a ring of one-second buckets.
+type evictionRate struct {
+	now     func() time.Time
+	seconds [rateBuckets]int64
+	counts  [rateBuckets]uint64
+}
+
+func newEvictionRate() *evictionRate {
+	return &evictionRate{now: time.Now}
+}
+
+// record counts one eviction in the bucket of the current second.
+func (r *evictionRate) record() {
+	sec := r.now().Unix()
+	i := int(sec % int64(rateBuckets))
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// rate returns the evictions per second over the window ending now,
+// counted in whole seconds including the current one.
+func (r *evictionRate) rate(window time.Duration) float64 {
+	if window > MaxEvictionRateWindow {
+		window = MaxEvictionRateWindow
+	}
+	n := int64(window / time.Second)
+	if n <= 0 {
+		return 0
+	}
+	sec := r.now().Unix()
+	var total uint64
+	for i := range r.seconds {
+		if age := sec - r.seconds[i]; age >= 0 && age < n {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / float64(n)
+}
+
+// EnableRateTracking starts recording the times of evictions made to free
+// capacity, as reported by EvictionRate. Explicit removals are not counted.
+func (c *Cache) EnableRateTracking() {
+	c.lock.Lock()
+	if c.evictRate == nil {
+		c.evictRate = newEvictionRate()
+	}
+	c.lock.Unlock()
+}
+
+// EvictionRate returns the number of evictions per second over the given
+// window, which is rounded down to whole seconds and capped at
+// MaxEvictionRateWindow. It returns 0 if rate tracking is disabled.
+func (c *Cache) EvictionRate(window time.Duration) float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.evictRate == nil {
+		return 0
+	}
+	return c.evictRate.rate(window)
+}
@@ -0,0 +1,51 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheEvictionRate(t *testing.T) {
+	l, err := New(1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(0, 0)
+	l.Add(1, 1)
+	if r := l.EvictionRate(time.Minute); r != 0 {
+		t.Fatalf("rate tracking should be disabled: %v", r)
+	}
+
+	l.EnableRateTracking()
+	now := time.Unix(1000, 0)
+	l.evictRate.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		l.Add(i+2, i)
+	}
+	now = now.Add(5 * time.Second)
+	for i := 0; i < 20; i++ {
+		l.Add(i+100, i)
+	}
+	l.Remove(119)
+
+	if r := l.EvictionRate(time.Second); r != 20 {
+		t.Fatalf("bad rate: %v", r)
+	}
+	if r := l.EvictionRate(10 * time.Second); r != 3 {
+		t.Fatalf("bad rate: %v", r)
+	}
+	if r := l.EvictionRate(time.Hour); r != 0.5 {
+		t.Fatalf("bad rate: %v", r)
+	}
+
+	now = now.Add(MaxEvictionRateWindow)
+	if r := l.EvictionRate(time.Hour); r != 0 {
+		t.Fatalf("old evictions should have expired: %v", r)
+	}
+	l.Add(200, 200)
+	l.Add(201, 201)
+	if r := l.EvictionRate(time.Second); r != 1 {
+		t.Fatalf("bad rate: %v", r)
+	}
+}
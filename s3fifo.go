@@ -0,0 +1,277 @@
+package lru
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// DefaultS3FIFOSmallRatio is the ratio of the S3-FIFO cache dedicated to
+// the small queue of new entries.
+const DefaultS3FIFOSmallRatio = 0.10
+
+// s3fifoMaxFreq is the maximum access count of an S3-FIFO entry.
+const s3fifoMaxFreq = 3
+
+// S3FIFOCache is a thread-safe fixed size S3-FIFO cache. New entries enter
+// a small FIFO queue, and only move to the main FIFO queue if accessed
+// again before reaching its end, otherwise they are evicted and their key
+// is remembered in a ghost queue, like the 2Q ghost list. Keys found in
+// the ghost queue are added straight to the main queue. Entries reaching
+// the end of the main queue are reinserted as long as they were accessed
+// since. This resists scans like 2Q, without moving entries on lookup:
+// Get only increments a small counter under a shared lock.
+type S3FIFOCache struct {
+	size       int
+	smallSize  int
+	smallRatio float64
+	items      map[interface{}]*list.Element
+	small      *list.List // of *s3fifoEntry, newest first
+	main       *list.List // of *s3fifoEntry, newest first
+	ghost      simplelru.LRUCache
+	lock       sync.RWMutex
+}
+
+// s3fifoEntry is an entry of an S3FIFOCache.
+type s3fifoEntry struct {
+	key, value interface{}
+	freq       uint32 // accessed atomically
+	main       bool
+}
+
+// NewS3FIFO creates an S3FIFOCache of the given size using the default
+// small queue ratio.
+func NewS3FIFO(size int) (*S3FIFOCache, error) {
+	return NewS3FIFOParams(size, DefaultS3FIFOSmallRatio)
+}
+
+// NewS3FIFOParams creates an S3FIFOCache of the given size, a smallRatio of
+// which is dedicated to the small queue, holding at least one entry. The
+// ghost queue remembers as many keys as the main queue holds entries.
+func NewS3FIFOParams(size int, smallRatio float64) (*S3FIFOCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	if smallRatio < 0.0 || smallRatio > 1.0 {
+		return nil, fmt.Errorf("invalid small ratio")
+	}
+	smallSize, ghostSize := s3fifoSizes(size, smallRatio)
+	ghost, err := simplelru.NewGhost(ghostSize)
+	if err != nil {
+		return nil, err
+	}
+	c := &S3FIFOCache{
+		size:       size,
+		smallSize:  smallSize,
+		smallRatio: smallRatio,
+		items:      make(map[interface{}]*list.Element),
+		small:      list.New(),
+		main:       list.New(),
+		ghost:      ghost,
+	}
+	return c, nil
+}
+
+// s3fifoSizes returns the sizes of the small and ghost queues of a cache.
+func s3fifoSizes(size int, smallRatio float64) (smallSize, ghostSize int) {
+	smallSize = int(float64(size) * smallRatio)
+	if smallSize == 0 {
+		smallSize = 1
+	}
+	ghostSize = size - smallSize
+	if ghostSize == 0 {
+		ghostSize = 1
+	}
+	return smallSize, ghostSize
+}
+
+// touch counts an access to an entry, up to s3fifoMaxFreq.
+func (e *s3fifoEntry) touch() {
+	for {
+		freq := atomic.LoadUint32(&e.freq)
+		if freq >= s3fifoMaxFreq || atomic.CompareAndSwapUint32(&e.freq, freq, freq+1) {
+			return
+		}
+	}
+}
+
+// Get looks up a key's value from the cache, counting the access.
+func (c *S3FIFOCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*s3fifoEntry)
+	e.touch()
+	return e.value, true
+}
+
+// Add adds a value to the cache, counting an access to an existing key.
+// Returns true if an eviction occurred.
+func (c *S3FIFOCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*s3fifoEntry)
+		e.value = value
+		e.touch()
+		return false
+	}
+
+	// Check the ghost queue first, as evicting adds to it
+	ghost := c.ghost.Remove(key)
+	for len(c.items) >= c.size {
+		c.evict()
+		evicted = true
+	}
+	e := &s3fifoEntry{key: key, value: value}
+	if ghost {
+		e.main = true
+		c.items[key] = c.main.PushFront(e)
+	} else {
+		c.items[key] = c.small.PushFront(e)
+	}
+	return evicted
+}
+
+// evict evicts an entry from the small queue if it is over its size, or
+// from the main queue otherwise.
+func (c *S3FIFOCache) evict() {
+	if c.small.Len() >= c.smallSize || c.main.Len() == 0 {
+		c.evictSmall()
+	} else {
+		c.evictMain()
+	}
+}
+
+// evictSmall evicts the oldest entry of the small queue not accessed
+// again into the ghost queue, moving the entries accessed again to the
+// main queue, which may evict from the main queue instead.
+func (c *S3FIFOCache) evictSmall() {
+	for elem := c.small.Back(); elem != nil; elem = c.small.Back() {
+		e := c.small.Remove(elem).(*s3fifoEntry)
+		if atomic.LoadUint32(&e.freq) == 0 {
+			delete(c.items, e.key)
+			c.ghost.Add(e.key, nil)
+			return
+		}
+		atomic.StoreUint32(&e.freq, 0)
+		e.main = true
+		c.items[e.key] = c.main.PushFront(e)
+		if c.main.Len() > c.size-c.smallSize {
+			c.evictMain()
+			return
+		}
+	}
+	c.evictMain()
+}
+
+// evictMain evicts the oldest entry of the main queue not accessed since
+// it was last reinserted, reinserting the others with one access less.
+func (c *S3FIFOCache) evictMain() {
+	for elem := c.main.Back(); elem != nil; elem = c.main.Back() {
+		e := elem.Value.(*s3fifoEntry)
+		if freq := atomic.LoadUint32(&e.freq); freq > 0 {
+			atomic.StoreUint32(&e.freq, freq-1)
+			c.main.MoveToFront(elem)
+			continue
+		}
+		c.main.Remove(elem)
+		delete(c.items, e.key)
+		return
+	}
+}
+
+// Peek returns the key value (or undefined if not found) without counting
+// the access.
+func (c *S3FIFOCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if elem, ok := c.items[key]; ok {
+		return elem.Value.(*s3fifoEntry).value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without counting the access.
+func (c *S3FIFOCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Remove removes the provided key from the cache, or from the ghost queue,
+// returning if the key was contained.
+func (c *S3FIFOCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.ghost.Remove(key)
+		return false
+	}
+	if elem.Value.(*s3fifoEntry).main {
+		c.main.Remove(elem)
+	} else {
+		c.small.Remove(elem)
+	}
+	delete(c.items, key)
+	return true
+}
+
+// Keys returns a slice of the keys in the cache: the small queue keys then
+// the main queue keys, each from oldest to newest.
+func (c *S3FIFOCache) Keys() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := make([]interface{}, 0, len(c.items))
+	for _, l := range []*list.List{c.small, c.main} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			keys = append(keys, elem.Value.(*s3fifoEntry).key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *S3FIFOCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.items)
+}
+
+// Resize changes the cache size, keeping the small queue ratio, and
+// returns the number of entries evicted. The ghost queue follows the size
+// of the main queue. Sizes below 1 are raised to 1.
+func (c *S3FIFOCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var ghostSize int
+	c.size = size
+	c.smallSize, ghostSize = s3fifoSizes(size, c.smallRatio)
+	for len(c.items) > size {
+		c.evict()
+		evicted++
+	}
+	c.ghost.Resize(ghostSize)
+	return evicted
+}
+
+// Purge is used to completely clear the cache, including the ghost queue.
+func (c *S3FIFOCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[interface{}]*list.Element)
+	c.small.Init()
+	c.main.Init()
+	c.ghost.Purge()
+}
@@ -0,0 +1,116 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkS3FIFO_Rand(b *testing.B) {
+	l, err := NewS3FIFO(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestS3FIFO(t *testing.T) {
+	l, err := NewS3FIFOParams(4, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("should not evict")
+		}
+	}
+
+	// 0 was accessed again and moves to main, 1 is evicted
+	l.Get(0)
+	if !l.Add(4, 4) {
+		t.Fatalf("should evict")
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{2, 3, 4, 0}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// 1 is in the ghost queue and goes straight to main
+	l.Add(1, 1)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{3, 4, 0, 1}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// Scanned keys go to the ghost queue
+	l.Get(0)
+	l.Add(5, 5)
+	l.Add(6, 6)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{5, 6, 0, 1}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// Main reinserts 0 once accessed, evicting 1
+	l.Add(3, 3)
+	l.Add(7, 7)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{6, 7, 3, 0}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	if v, ok := l.Peek(0); !ok || v != 0 || !l.Contains(0) {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if !l.Remove(0) || l.Remove(0) {
+		t.Fatalf("bad remove")
+	}
+	l.Purge()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad purge")
+	}
+	if _, err := NewS3FIFOParams(4, 2); err == nil {
+		t.Fatalf("should reject ratio")
+	}
+	if _, err := NewS3FIFO(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
+
+// Test that frequently used entries survive a scan
+func TestS3FIFO_Scan(t *testing.T) {
+	l, err := NewS3FIFO(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		l.Add(i, i)
+		l.Get(i)
+	}
+	for i := 1000; i < 1500; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 100 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	for i := 0; i < 50; i++ {
+		if !l.Contains(i) {
+			t.Fatalf("should contain %d", i)
+		}
+	}
+}
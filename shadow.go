@@ -0,0 +1,72 @@
+package lru
+
+import "github.com/hashicorp/golang-lru/simplelru"
+
+// ShadowCache is a cache policy that can be evaluated against the
+// accesses of a Cache. Interface implements it, as do the caches of this
+// package other than TwoQueueCache and ARCCache, which are adapted by
+// AsInterface.
+type ShadowCache interface {
+	Get(key interface{}) (value interface{}, ok bool)
+	Add(key, value interface{}) (evicted bool)
+	Len() int
+}
+
+// shadow tracks the lookups replayed against a ShadowCache.
+type shadow struct {
+	cache        ShadowCache
+	hits, misses uint64
+}
+
+// SetShadow mirrors every subsequent Get and add of the cache to the
+// provided shadow cache, which only records whether it would have hit.
+// Values are never served from the shadow. A nil shadow stops mirroring.
+// Counters restart whenever the shadow is replaced.
+func (c *Cache) SetShadow(sc ShadowCache) {
+	c.lock.Lock()
+	c.shadow = nil
+	if sc != nil {
+		c.shadow = &shadow{cache: sc}
+	}
+	c.lock.Unlock()
+}
+
+// ShadowStats returns the lookup counters of the shadow cache along with
+// its current length. Evictions and capacity are not tracked for shadows.
+func (c *Cache) ShadowStats() simplelru.Stats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.shadow == nil {
+		return simplelru.Stats{}
+	}
+	st := simplelru.Stats{
+		Hits:   c.shadow.hits,
+		Misses: c.shadow.misses,
+		Len:    c.shadow.cache.Len(),
+	}
+	if lookups := st.Hits + st.Misses; lookups > 0 {
+		st.HitRatio = float64(st.Hits) / float64(lookups)
+	}
+	return st
+}
+
+// shadowGet mirrors a lookup to the shadow cache, if any.
+// It must be called with the lock held.
+func (c *Cache) shadowGet(key interface{}) {
+	if c.shadow == nil {
+		return
+	}
+	if _, ok := c.shadow.cache.Get(key); ok {
+		c.shadow.hits++
+	} else {
+		c.shadow.misses++
+	}
+}
+
+// shadowAdd mirrors an add to the shadow cache, if any.
+// It must be called with the lock held.
+func (c *Cache) shadowAdd(key, value interface{}) {
+	if c.shadow != nil {
+		c.shadow.cache.Add(key, value)
+	}
+}
@@ -0,0 +1,47 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+func TestCacheShadow(t *testing.T) {
+	l, err := New(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	shadow, err := NewByPolicy("2q", 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if st := l.ShadowStats(); st != (simplelru.Stats{}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	l.SetShadow(shadow)
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 4; i++ {
+		l.Get(i)
+	}
+	l.ContainsOrAdd(9, 9)
+
+	if st := l.Stats(); st.Hits != 2 || st.Misses != 2 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+	st := l.ShadowStats()
+	if st.Hits != 4 || st.Misses != 0 || st.Len != 4 || st.HitRatio != 1 {
+		t.Fatalf("bad shadow stats: %+v", st)
+	}
+	if !shadow.Contains(9) {
+		t.Fatalf("adds should be mirrored")
+	}
+
+	l.SetShadow(nil)
+	l.Get(0)
+	if st := l.ShadowStats(); st != (simplelru.Stats{}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
@@ -0,0 +1,121 @@
+// Package sharded provides a thread-safe LRU cache partitioned into
+// independently locked shards, for workloads where a single lock is
+// contended.
+package sharded
+
+import (
+	"errors"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// HashFunc maps a key to the hash used to pick its shard.
+type HashFunc func(key interface{}) uint64
+
+// Cache is a thread-safe fixed size cache made of independently locked
+// LRU shards. Each key belongs to the shard picked by its hash, so
+// operations on keys of different shards do not contend. Recency is
+// tracked per shard: an entry is evicted when it is the least recently
+// used of its shard, not necessarily of the whole cache.
+type Cache struct {
+	shards []*lru.Cache
+	hash   HashFunc
+}
+
+// New creates a Cache holding size entries split evenly across the given
+// number of shards: each holds size/shards entries, and the first
+// size%shards shards hold one more. If hash is nil, DefaultHash is used.
+func New(size, shards int, hash HashFunc) (*Cache, error) {
+	if shards <= 0 {
+		return nil, errors.New("must provide a positive number of shards")
+	}
+	if size < shards {
+		return nil, errors.New("size must be at least the number of shards")
+	}
+	if hash == nil {
+		hash = DefaultHash
+	}
+	c := &Cache{
+		shards: make([]*lru.Cache, shards),
+		hash:   hash,
+	}
+	for i := range c.shards {
+		shardSize := size / shards
+		if i < size%shards {
+			shardSize++
+		}
+		shard, err := lru.New(shardSize)
+		if err != nil {
+			return nil, err
+		}
+		c.shards[i] = shard
+	}
+	return c, nil
+}
+
+// DefaultHash hashes keys with lru.HashKey: FNV-1a over the key, walking
+// structs and arrays field by field, with pointers and channels hashed by
+// address.
+func DefaultHash(key interface{}) uint64 {
+	return lru.HashKey(key)
+}
+
+// shard returns the shard holding key.
+func (c *Cache) shard(key interface{}) *lru.Cache {
+	return c.shards[c.hash(key)%uint64(len(c.shards))]
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *Cache) Add(key, value interface{}) (evicted bool) {
+	return c.shard(key).Add(key, value)
+}
+
+// Get looks up a key's value from the cache.
+func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
+	return c.shard(key).Get(key)
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness or deleting it for being stale.
+func (c *Cache) Contains(key interface{}) bool {
+	return c.shard(key).Contains(key)
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
+	return c.shard(key).Peek(key)
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *Cache) Remove(key interface{}) (present bool) {
+	return c.shard(key).Remove(key)
+}
+
+// Purge is used to completely clear the cache, one shard at a time.
+func (c *Cache) Purge() {
+	for _, shard := range c.shards {
+		shard.Purge()
+	}
+}
+
+// Keys returns a slice of the keys in the cache, shard by shard, each
+// from oldest to newest. The shards are not locked together, so the keys
+// of a cache modified concurrently are not a consistent snapshot.
+func (c *Cache) Keys() []interface{} {
+	var keys []interface{}
+	for _, shard := range c.shards {
+		keys = shard.AppendKeys(keys)
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache, summed shard by shard.
+func (c *Cache) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
@@ -0,0 +1,139 @@
+package sharded
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func BenchmarkCache_Parallel(b *testing.B) {
+	c, err := New(8192, 16, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%2 == 0 {
+				c.Add(i%16384, i)
+			} else {
+				c.Get(i % 16384)
+			}
+			i++
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	c, err := New(64, 4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 16; i++ {
+				k := strconv.Itoa(g*16 + i)
+				c.Add(k, k)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Len() > 64 || len(c.Keys()) != c.Len() {
+		t.Fatalf("bad len: %v keys: %v", c.Len(), len(c.Keys()))
+	}
+	for _, k := range c.Keys() {
+		if v, ok := c.Get(k); !ok || v != k {
+			t.Fatalf("bad: %v %v", v, ok)
+		}
+	}
+	if !c.Contains(c.Keys()[0]) {
+		t.Fatalf("should contain its keys")
+	}
+	k := c.Keys()[0]
+	if !c.Remove(k) || c.Contains(k) {
+		t.Fatalf("should remove")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Fatalf("bad len: %v", c.Len())
+	}
+}
+
+func TestCache_Hash(t *testing.T) {
+	// Every key lands in the first shard, which holds 2 entries
+	c, err := New(8, 4, func(key interface{}) uint64 { return 0 })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.Add(1, 1)
+	c.Add(2, 2)
+	if !c.Add(3, 3) {
+		t.Fatalf("should evict from the shard")
+	}
+	if c.Contains(1) || c.Len() != 2 {
+		t.Fatalf("bad shard eviction")
+	}
+	if v, ok := c.Peek(3); !ok || v != 3 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+// Test that the shard sizes add up to the requested size
+func TestCache_Size(t *testing.T) {
+	c, err := New(10, 3, func(key interface{}) uint64 { return uint64(key.(int)) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 30; i++ {
+		c.Add(i, i)
+	}
+	if c.Len() != 10 {
+		t.Fatalf("bad len: %v", c.Len())
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(8, 0, nil); err == nil {
+		t.Fatalf("should reject zero shards")
+	}
+	if _, err := New(2, 4, nil); err == nil {
+		t.Fatalf("should reject fewer entries than shards")
+	}
+}
+
+func TestDefaultHash(t *testing.T) {
+	type point struct{ X, Y int }
+	for _, k := range []interface{}{"a", 1, int64(1), int32(1), uint(1), uint64(1), uint32(1), point{1, 2}} {
+		if DefaultHash(k) != DefaultHash(k) {
+			t.Fatalf("hash of %v should be stable", k)
+		}
+	}
+	if DefaultHash("a") == DefaultHash("b") {
+		t.Fatalf("bad hash")
+	}
+}
+
+// Test that pointer keys stay on their shard when what they point to changes
+func TestCache_PointerKey(t *testing.T) {
+	type point struct{ X, Y int }
+	c, err := New(64, 4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	keys := make([]*point, 16)
+	for i := range keys {
+		keys[i] = &point{i, i}
+		c.Add(keys[i], i)
+	}
+	for i, k := range keys {
+		k.X = 100 + i
+		if v, ok := c.Get(k); !ok || v != i {
+			t.Fatalf("bad: %v %v", v, ok)
+		}
+	}
+}
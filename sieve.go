@@ -0,0 +1,182 @@
+package lru
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// SieveCache is a thread-safe fixed size SIEVE cache. Entries are queued
+// in insertion order and never moved: a lookup only marks its entry as
+// visited. To evict, a hand sweeps from the oldest towards the newest
+// entries, clearing the visited marks it passes, and evicts the first
+// entry not visited, staying there for the next eviction. Entries visited
+// are thus kept lazily, while new entries not visited again are evicted
+// quickly. SIEVE outperforms LRU on many web traces, and Get only sets a
+// mark under a shared lock.
+type SieveCache struct {
+	size  int
+	items map[interface{}]*list.Element
+	queue *list.List // of *sieveEntry, newest first
+	hand  *list.Element
+	lock  sync.RWMutex
+}
+
+// sieveEntry is an entry of a SieveCache.
+type sieveEntry struct {
+	key, value interface{}
+	visited    uint32 // accessed atomically
+}
+
+// NewSieve creates a SieveCache of the given size.
+func NewSieve(size int) (*SieveCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	c := &SieveCache{
+		size:  size,
+		items: make(map[interface{}]*list.Element),
+		queue: list.New(),
+	}
+	return c, nil
+}
+
+// Get looks up a key's value from the cache, marking it visited.
+func (c *SieveCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*sieveEntry)
+	if atomic.LoadUint32(&e.visited) == 0 {
+		atomic.StoreUint32(&e.visited, 1)
+	}
+	return e.value, true
+}
+
+// Add adds a value to the cache, marking an existing key visited. Returns
+// true if an eviction occurred.
+func (c *SieveCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*sieveEntry)
+		e.value = value
+		atomic.StoreUint32(&e.visited, 1)
+		return false
+	}
+	if c.queue.Len() >= c.size {
+		c.evict()
+		evicted = true
+	}
+	c.items[key] = c.queue.PushFront(&sieveEntry{key: key, value: value})
+	return evicted
+}
+
+// evict moves the hand to the oldest entry not visited, clearing the
+// visited marks on the way, and removes it.
+func (c *SieveCache) evict() {
+	elem := c.hand
+	if elem == nil {
+		elem = c.queue.Back()
+	}
+	for {
+		e := elem.Value.(*sieveEntry)
+		if atomic.LoadUint32(&e.visited) == 0 {
+			break
+		}
+		atomic.StoreUint32(&e.visited, 0)
+		if elem = elem.Prev(); elem == nil {
+			elem = c.queue.Back()
+		}
+	}
+	c.hand = elem
+	c.removeElement(elem)
+}
+
+// removeElement removes an element, moving the hand to the next newer
+// entry if it points there.
+func (c *SieveCache) removeElement(elem *list.Element) {
+	if c.hand == elem {
+		c.hand = elem.Prev()
+	}
+	c.queue.Remove(elem)
+	delete(c.items, elem.Value.(*sieveEntry).key)
+}
+
+// Peek returns the key value (or undefined if not found) without marking
+// it visited.
+func (c *SieveCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if elem, ok := c.items[key]; ok {
+		return elem.Value.(*sieveEntry).value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without marking it visited.
+func (c *SieveCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *SieveCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *SieveCache) Keys() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := make([]interface{}, 0, c.queue.Len())
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		keys = append(keys, elem.Value.(*sieveEntry).key)
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *SieveCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.queue.Len()
+}
+
+// Resize changes the cache size, returning the number of entries evicted.
+// Sizes below 1 are raised to 1.
+func (c *SieveCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = size
+	for c.queue.Len() > size {
+		c.evict()
+		evicted++
+	}
+	return evicted
+}
+
+// Purge is used to completely clear the cache.
+func (c *SieveCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[interface{}]*list.Element)
+	c.queue.Init()
+	c.hand = nil
+}
@@ -0,0 +1,83 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkSieve_Rand(b *testing.B) {
+	l, err := NewSieve(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestSieve(t *testing.T) {
+	l, err := NewSieve(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("should not evict")
+		}
+	}
+
+	// The hand skips the visited 0 and 1, evicting 2
+	l.Get(0)
+	l.Get(1)
+	l.Get(3)
+	if !l.Add(4, 4) {
+		t.Fatalf("should evict")
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{0, 1, 3, 4}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// The hand stays after 2, clearing 3, and evicts 4 which is newer
+	l.Add(5, 5)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{0, 1, 3, 5}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	// The hand clears 5 and wraps around to 0, cleared on the first sweep
+	l.Get(5)
+	l.Add(6, 6)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{1, 3, 5, 6}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	if v, ok := l.Peek(1); !ok || v != 1 || !l.Contains(1) {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if !l.Remove(1) || l.Remove(1) || l.Len() != 3 {
+		t.Fatalf("bad remove")
+	}
+	l.Purge()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad purge")
+	}
+	if _, err := NewSieve(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
@@ -0,0 +1,96 @@
+package simplelru
+
+import "container/list"
+
+// Deque is a non-thread safe double-ended queue of keyed entries bounded
+// in length, built on LRU. The front is the newest end of the underlying
+// LRU and the back its oldest end. Pushing beyond the capacity drops the
+// entry at the opposite end, invoking the eviction callback: PushFront
+// drops from the back, keeping the last pushed entries like LRU.Add does,
+// and PushBack drops from the front. Popping an entry does not invoke the
+// eviction callback.
+type Deque struct {
+	lru *LRU
+}
+
+// NewDeque constructs a Deque holding at most size entries.
+func NewDeque(size int, onEvict EvictCallback) (*Deque, error) {
+	lru, err := NewLRU(size, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	return &Deque{lru: lru}, nil
+}
+
+// PushFront pushes an entry at the front, moving the key there if it is
+// already queued. Returns true if an entry was dropped from the back.
+func (d *Deque) PushFront(key, value interface{}) (dropped bool) {
+	return d.lru.Add(key, value)
+}
+
+// PushBack pushes an entry at the back, moving the key there if it is
+// already queued. Returns true if an entry was dropped from the front.
+func (d *Deque) PushBack(key, value interface{}) (dropped bool) {
+	c := d.lru
+	c.adds++
+	if ent, ok := c.items[key]; ok {
+		c.evictList.MoveToBack(ent)
+		ent.Value.(*entry).value = value
+		return false
+	}
+	if c.seen != nil {
+		c.seen.add(HashKey(key))
+	}
+	dropped = c.evictList.Len() >= c.size
+	if dropped {
+		c.evictions++
+		c.removeElement(c.evictList.Front())
+	}
+	c.items[key] = c.evictList.PushBack(c.newEntry(key, value))
+	return dropped
+}
+
+// PopFront removes and returns the entry at the front.
+func (d *Deque) PopFront() (key, value interface{}, ok bool) {
+	return d.pop(d.lru.evictList.Front())
+}
+
+// PopBack removes and returns the entry at the back.
+func (d *Deque) PopBack() (key, value interface{}, ok bool) {
+	return d.pop(d.lru.evictList.Back())
+}
+
+// pop removes and returns the entry of a list element, if any.
+func (d *Deque) pop(e *list.Element) (key, value interface{}, ok bool) {
+	if e == nil {
+		return nil, nil, false
+	}
+	kv := d.lru.unlinkElement(e)
+	return kv.key, kv.value, true
+}
+
+// Peek returns the value of a key without moving it.
+func (d *Deque) Peek(key interface{}) (value interface{}, ok bool) {
+	return d.lru.Peek(key)
+}
+
+// Remove removes the provided key, returning if it was queued.
+// The eviction callback is invoked.
+func (d *Deque) Remove(key interface{}) (present bool) {
+	return d.lru.Remove(key)
+}
+
+// Keys returns a slice of the queued keys, from the back to the front.
+func (d *Deque) Keys() []interface{} {
+	return d.lru.Keys()
+}
+
+// Len returns the number of queued entries.
+func (d *Deque) Len() int {
+	return d.lru.Len()
+}
+
+// Cap returns the maximum number of queued entries.
+func (d *Deque) Cap() int {
+	return d.lru.Cap()
+}
@@ -0,0 +1,52 @@
+package simplelru
+
+import "testing"
+
+func TestDeque(t *testing.T) {
+	var dropped []interface{}
+	d, err := NewDeque(3, func(k, v interface{}) {
+		dropped = append(dropped, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, ok := d.PopFront(); ok {
+		t.Fatalf("should be empty")
+	}
+
+	for i := 0; i < 4; i++ {
+		d.PushFront(i, i)
+	}
+	if len(dropped) != 1 || dropped[0] != 0 {
+		t.Fatalf("PushFront should drop from the back: %v", dropped)
+	}
+	if !d.PushBack(4, 4) {
+		t.Fatalf("should drop")
+	}
+	if len(dropped) != 2 || dropped[1] != 3 {
+		t.Fatalf("PushBack should drop from the front: %v", dropped)
+	}
+	if keys := d.Keys(); len(keys) != 3 || keys[0] != 4 || keys[1] != 1 || keys[2] != 2 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// Pushing a queued key moves it
+	if d.PushBack(2, 20) {
+		t.Fatalf("should not drop")
+	}
+	if k, v, ok := d.PopBack(); !ok || k != 2 || v != 20 {
+		t.Fatalf("bad: %v %v %v", k, v, ok)
+	}
+	if k, v, ok := d.PopFront(); !ok || k != 1 || v != 1 {
+		t.Fatalf("bad: %v %v %v", k, v, ok)
+	}
+	if len(dropped) != 2 {
+		t.Fatalf("popping should not invoke the callback: %v", dropped)
+	}
+	if d.Len() != 1 || d.Cap() != 3 {
+		t.Fatalf("bad len/cap: %v %v", d.Len(), d.Cap())
+	}
+	if err := d.lru.Check(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package simplelru
+
+import (
+	"container/list"
+	"errors"
+)
+
+// Ghost is a non-thread safe fixed size set of keys in LRU order, meant
+// for the ghost lists of caches, which remember the keys recently evicted
+// without their values, such as those of 2Q, ARC and S3-FIFO. It
+// implements LRUCache so that it can be used in place of an LRU storing
+// nil values, but takes less memory per key: values are discarded, and
+// Get and Peek report a nil value for the keys contained.
+type Ghost struct {
+	size  int
+	keys  *list.List // of keys, newest first
+	items map[interface{}]*list.Element
+}
+
+// NewGhost constructs a Ghost holding at most size keys.
+func NewGhost(size int) (*Ghost, error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	g := &Ghost{
+		size:  size,
+		keys:  list.New(),
+		items: make(map[interface{}]*list.Element),
+	}
+	return g, nil
+}
+
+// Add adds a key, discarding the value, or moves it to the newest end if
+// already contained. Returns true if the oldest key was evicted.
+func (g *Ghost) Add(key, _ interface{}) (evicted bool) {
+	if elem, ok := g.items[key]; ok {
+		g.keys.MoveToFront(elem)
+		return false
+	}
+	if g.keys.Len() >= g.size {
+		g.RemoveOldest()
+		evicted = true
+	}
+	g.items[key] = g.keys.PushFront(key)
+	return evicted
+}
+
+// Get reports whether a key is contained, moving it to the newest end.
+// The value is always nil.
+func (g *Ghost) Get(key interface{}) (value interface{}, ok bool) {
+	elem, ok := g.items[key]
+	if ok {
+		g.keys.MoveToFront(elem)
+	}
+	return nil, ok
+}
+
+// Contains checks if a key is contained, without moving it.
+func (g *Ghost) Contains(key interface{}) (ok bool) {
+	_, ok = g.items[key]
+	return ok
+}
+
+// Peek reports whether a key is contained, without moving it. The value
+// is always nil.
+func (g *Ghost) Peek(key interface{}) (value interface{}, ok bool) {
+	_, ok = g.items[key]
+	return nil, ok
+}
+
+// Remove removes the provided key, returning if it was contained.
+func (g *Ghost) Remove(key interface{}) (present bool) {
+	elem, ok := g.items[key]
+	if ok {
+		g.keys.Remove(elem)
+		delete(g.items, key)
+	}
+	return ok
+}
+
+// RemoveOldest removes the oldest key and returns it, with a nil value.
+func (g *Ghost) RemoveOldest() (key, value interface{}, ok bool) {
+	elem := g.keys.Back()
+	if elem == nil {
+		return nil, nil, false
+	}
+	g.keys.Remove(elem)
+	delete(g.items, elem.Value)
+	return elem.Value, nil, true
+}
+
+// GetOldest returns the oldest key, with a nil value.
+func (g *Ghost) GetOldest() (key, value interface{}, ok bool) {
+	elem := g.keys.Back()
+	if elem == nil {
+		return nil, nil, false
+	}
+	return elem.Value, nil, true
+}
+
+// Keys returns a slice of the keys, from oldest to newest.
+func (g *Ghost) Keys() []interface{} {
+	keys := make([]interface{}, 0, g.keys.Len())
+	for elem := g.keys.Back(); elem != nil; elem = elem.Prev() {
+		keys = append(keys, elem.Value)
+	}
+	return keys
+}
+
+// Len returns the number of keys.
+func (g *Ghost) Len() int {
+	return g.keys.Len()
+}
+
+// Cap returns the maximum number of keys.
+func (g *Ghost) Cap() int {
+	return g.size
+}
+
+// Purge removes all the keys.
+func (g *Ghost) Purge() {
+	g.keys.Init()
+	g.items = make(map[interface{}]*list.Element)
+}
+
+// Resize changes the maximum number of keys, returning the number of
+// oldest keys evicted.
+func (g *Ghost) Resize(size int) (evicted int) {
+	for g.keys.Len() > size {
+		g.RemoveOldest()
+		evicted++
+	}
+	g.size = size
+	return evicted
+}
@@ -0,0 +1,53 @@
+package simplelru
+
+import "testing"
+
+func TestGhost(t *testing.T) {
+	g, err := NewGhost(3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var _ LRUCache = g
+
+	for i := 0; i < 3; i++ {
+		if g.Add(i, i) {
+			t.Fatalf("should not evict")
+		}
+	}
+	if v, ok := g.Peek(1); !ok || v != nil {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if _, ok := g.Get(0); !ok {
+		t.Fatalf("should contain 0")
+	}
+	if !g.Add(3, nil) || g.Contains(1) {
+		t.Fatalf("should evict 1")
+	}
+	if keys := g.Keys(); !equalKeys(keys, []interface{}{2, 0, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if k, v, ok := g.GetOldest(); !ok || k != 2 || v != nil {
+		t.Fatalf("bad oldest: %v %v %v", k, v, ok)
+	}
+	if k, _, ok := g.RemoveOldest(); !ok || k != 2 || g.Len() != 2 {
+		t.Fatalf("bad oldest: %v %v", k, ok)
+	}
+
+	if !g.Remove(0) || g.Remove(0) {
+		t.Fatalf("bad remove")
+	}
+	g.Add(4, nil)
+	if evicted := g.Resize(1); evicted != 1 || g.Cap() != 1 || !g.Contains(4) {
+		t.Fatalf("bad resize: %v %v", evicted, g.Keys())
+	}
+	g.Purge()
+	if g.Len() != 0 {
+		t.Fatalf("bad len: %v", g.Len())
+	}
+	if _, _, ok := g.RemoveOldest(); ok {
+		t.Fatalf("should be empty")
+	}
+	if _, err := NewGhost(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
@@ -0,0 +1,110 @@
+package simplelru
+
+import (
+	"math"
+	"reflect"
+)
+
+// FNV-1a parameters, as used by hash/fnv.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// HashKey hashes keys with FNV-1a without allocating. Strings and integers
+// are hashed directly. Structs, arrays, booleans, floats and complex
+// numbers are walked with reflect, hashing each field or element in turn.
+// Pointers and channels, which are compared by address, are hashed by
+// address too, so that changing what they point to does not change their
+// hash.
+func HashKey(key interface{}) uint64 {
+	h := uint64(fnvOffset)
+	switch k := key.(type) {
+	case string:
+		return hashString(h, k)
+	case int:
+		return hashUint64(h, uint64(k))
+	case int64:
+		return hashUint64(h, uint64(k))
+	case int32:
+		return hashUint64(h, uint64(k))
+	case uint:
+		return hashUint64(h, uint64(k))
+	case uint64:
+		return hashUint64(h, k)
+	case uint32:
+		return hashUint64(h, uint64(k))
+	}
+	return hashValue(h, reflect.ValueOf(key))
+}
+
+// hashValue adds v to the hash h, walking composite values.
+func hashValue(h uint64, v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return hashString(h, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashUint64(h, v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			return hashUint64(h, 1)
+		}
+		return hashUint64(h, 0)
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return hashFloat(hashFloat(h, real(c)), imag(c))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = hashValue(h, v.Index(i))
+		}
+		return h
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h = hashValue(h, v.Field(i))
+		}
+		return h
+	case reflect.Interface:
+		if v.IsNil() {
+			return hashUint64(h, 0)
+		}
+		return hashValue(h, v.Elem())
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer, reflect.Func, reflect.Map, reflect.Slice:
+		// Only pointers and channels can be keys, but the others are
+		// hashed by address as well rather than rejected
+		return hashUint64(h, uint64(v.Pointer()))
+	}
+	// nil
+	return h
+}
+
+// hashString adds s to the hash h.
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h
+}
+
+// hashUint64 adds the little-endian bytes of n to the hash h.
+func hashUint64(h, n uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= n & 0xff
+		h *= fnvPrime
+		n >>= 8
+	}
+	return h
+}
+
+// hashFloat adds f to the hash h. Zero and negative zero, which compare
+// equal, hash the same.
+func hashFloat(h uint64, f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return hashUint64(h, math.Float64bits(f))
+}
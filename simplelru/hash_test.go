@@ -0,0 +1,83 @@
+package simplelru
+
+import (
+	"math"
+	"testing"
+)
+
+type hashedKey struct {
+	ID int
+}
+
+type compositeKey struct {
+	Name  string
+	Flags [2]bool
+	Score float64
+	ptr   *hashedKey
+	any   interface{}
+}
+
+func BenchmarkHashKey_Struct(b *testing.B) {
+	k := compositeKey{Name: "tenant", Flags: [2]bool{true, false}, Score: 1.5}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		HashKey(k)
+	}
+}
+
+// String is not used for hashing, as it may not be stable
+func (k *hashedKey) String() string {
+	return "key"
+}
+
+func TestHashKey(t *testing.T) {
+	if HashKey("a") != HashKey("a") || HashKey("a") == HashKey("b") {
+		t.Fatalf("bad string hash")
+	}
+	if HashKey(hashedKey{1}) != HashKey(hashedKey{1}) || HashKey(hashedKey{1}) == HashKey(hashedKey{2}) {
+		t.Fatalf("bad struct hash")
+	}
+
+	// Pointers are hashed by address, not by what they point to
+	k := &hashedKey{1}
+	h := HashKey(k)
+	k.ID = 2
+	if HashKey(k) != h {
+		t.Fatalf("pointer hash should not depend on the pointee")
+	}
+	if HashKey(&hashedKey{2}) == h {
+		t.Fatalf("distinct pointers should hash differently")
+	}
+	if HashKey(nil) != HashKey(nil) {
+		t.Fatalf("bad nil hash")
+	}
+
+	// Composite keys are walked field by field
+	p := &hashedKey{1}
+	k1 := compositeKey{Name: "a", Flags: [2]bool{true, false}, Score: 1, ptr: p, any: 1}
+	k2 := k1
+	if HashKey(k1) != HashKey(k2) {
+		t.Fatalf("equal keys should hash the same")
+	}
+	for _, k := range []compositeKey{
+		{Name: "b", Flags: k1.Flags, Score: 1, ptr: p, any: 1},
+		{Name: "a", Flags: [2]bool{false, true}, Score: 1, ptr: p, any: 1},
+		{Name: "a", Flags: k1.Flags, Score: 2, ptr: p, any: 1},
+		{Name: "a", Flags: k1.Flags, Score: 1, ptr: &hashedKey{1}, any: 1},
+		{Name: "a", Flags: k1.Flags, Score: 1, ptr: p, any: "1"},
+	} {
+		if HashKey(k) == HashKey(k1) {
+			t.Fatalf("distinct keys should hash differently: %+v", k)
+		}
+	}
+	if HashKey(0.0) != HashKey(math.Copysign(0, -1)) {
+		t.Fatalf("zero and negative zero should hash the same")
+	}
+	if HashKey(true) == HashKey(false) {
+		t.Fatalf("bad bool hash")
+	}
+	var key interface{} = k1
+	if n := testing.AllocsPerRun(100, func() { HashKey(key) }); n != 0 {
+		t.Fatalf("hashing should not allocate: %v", n)
+	}
+}
@@ -0,0 +1,51 @@
+package simplelru
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of hash bits used to pick a register.
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct keys added to it in a fixed
+// 4KB, with a standard error of about 1.6%. Small cardinalities are counted
+// almost exactly. Only hashes are kept, so keys are not retained.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// add records a key by its hash.
+func (h *hyperLogLog) add(hash uint64) {
+	// Mix the hash so that the register index does not depend on the
+	// high bits of FNV alone, which vary little between short keys
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+
+	idx := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// estimate returns the estimated number of distinct keys added.
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
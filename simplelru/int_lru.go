@@ -0,0 +1,173 @@
+package simplelru
+
+import (
+	"container/list"
+	"errors"
+)
+
+// IntEvictCallback is used to get a callback when an IntLRU entry is evicted
+type IntEvictCallback func(key int64, value interface{})
+
+// IntLRU implements a non-thread safe fixed size LRU cache keyed by int64.
+// It avoids boxing keys into interfaces, which makes it cheaper than LRU
+// for integer keys such as IDs.
+type IntLRU struct {
+	size      int
+	evictList *list.List
+	items     map[int64]*list.Element
+	onEvict   IntEvictCallback
+}
+
+// intEntry is used to hold a value in the evictList of an IntLRU
+type intEntry struct {
+	key   int64
+	value interface{}
+}
+
+// NewIntLRU constructs an IntLRU of the given size
+func NewIntLRU(size int, onEvict IntEvictCallback) (*IntLRU, error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+	c := &IntLRU{
+		size:      size,
+		evictList: list.New(),
+		items:     make(map[int64]*list.Element),
+		onEvict:   onEvict,
+	}
+	return c, nil
+}
+
+// Purge is used to completely clear the cache. The eviction callback
+// is invoked for each entry from oldest to newest.
+func (c *IntLRU) Purge() {
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*intEntry)
+		if c.onEvict != nil {
+			c.onEvict(kv.key, kv.value)
+		}
+		delete(c.items, kv.key)
+	}
+	c.evictList.Init()
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *IntLRU) Add(key int64, value interface{}) (evicted bool) {
+	// Check for existing item
+	if ent, ok := c.items[key]; ok {
+		c.evictList.MoveToFront(ent)
+		ent.Value.(*intEntry).value = value
+		return false
+	}
+
+	// Add new item
+	c.items[key] = c.evictList.PushFront(&intEntry{key, value})
+
+	evict := c.evictList.Len() > c.size
+	// Verify size not exceeded
+	if evict {
+		c.removeOldest()
+	}
+	return evict
+}
+
+// Get looks up a key's value from the cache.
+func (c *IntLRU) Get(key int64) (value interface{}, ok bool) {
+	if ent, ok := c.items[key]; ok {
+		c.evictList.MoveToFront(ent)
+		return ent.Value.(*intEntry).value, true
+	}
+	return
+}
+
+// Contains checks if a key is in the cache, without updating the recent-ness
+// or deleting it for being stale.
+func (c *IntLRU) Contains(key int64) (ok bool) {
+	_, ok = c.items[key]
+	return ok
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *IntLRU) Peek(key int64) (value interface{}, ok bool) {
+	if ent, ok := c.items[key]; ok {
+		return ent.Value.(*intEntry).value, true
+	}
+	return nil, false
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *IntLRU) Remove(key int64) (present bool) {
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent)
+		return true
+	}
+	return false
+}
+
+// RemoveOldest removes the oldest item from the cache.
+func (c *IntLRU) RemoveOldest() (key int64, value interface{}, ok bool) {
+	ent := c.evictList.Back()
+	if ent != nil {
+		c.removeElement(ent)
+		kv := ent.Value.(*intEntry)
+		return kv.key, kv.value, true
+	}
+	return 0, nil, false
+}
+
+// GetOldest returns the oldest entry
+func (c *IntLRU) GetOldest() (key int64, value interface{}, ok bool) {
+	ent := c.evictList.Back()
+	if ent != nil {
+		kv := ent.Value.(*intEntry)
+		return kv.key, kv.value, true
+	}
+	return 0, nil, false
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *IntLRU) Keys() []int64 {
+	keys := make([]int64, 0, len(c.items))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		keys = append(keys, ent.Value.(*intEntry).key)
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *IntLRU) Len() int {
+	return c.evictList.Len()
+}
+
+// Resize changes the cache size.
+func (c *IntLRU) Resize(size int) (evicted int) {
+	diff := c.Len() - size
+	if diff < 0 {
+		diff = 0
+	}
+	for i := 0; i < diff; i++ {
+		c.removeOldest()
+	}
+	c.size = size
+	return diff
+}
+
+// removeOldest removes the oldest item from the cache.
+func (c *IntLRU) removeOldest() {
+	ent := c.evictList.Back()
+	if ent != nil {
+		c.removeElement(ent)
+	}
+}
+
+// removeElement is used to remove a given list element from the cache
+func (c *IntLRU) removeElement(e *list.Element) {
+	c.evictList.Remove(e)
+	kv := e.Value.(*intEntry)
+	delete(c.items, kv.key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
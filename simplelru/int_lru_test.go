@@ -0,0 +1,101 @@
+package simplelru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func benchmarkIntTrace(b *testing.B) []int64 {
+	trace := make([]int64, b.N)
+	for i := 0; i < b.N; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+	b.ResetTimer()
+	return trace
+}
+
+func BenchmarkLRU_Int64Keys(b *testing.B) {
+	l, err := NewLRU(8192, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	trace := benchmarkIntTrace(b)
+	b.ReportAllocs()
+	for _, k := range trace {
+		if _, ok := l.Get(k); !ok {
+			l.Add(k, nil)
+		}
+	}
+}
+
+func BenchmarkIntLRU(b *testing.B) {
+	l, err := NewIntLRU(8192, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	trace := benchmarkIntTrace(b)
+	b.ReportAllocs()
+	for _, k := range trace {
+		if _, ok := l.Get(k); !ok {
+			l.Add(k, nil)
+		}
+	}
+}
+
+func TestIntLRU(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k int64, v interface{}) {
+		if k != v.(int64) {
+			t.Fatalf("Evict values not equal (%v!=%v)", k, v)
+		}
+		evictCounter++
+	}
+	l, err := NewIntLRU(128, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := int64(0); i < 256; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evictCounter != 128 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i, k := range l.Keys() {
+		if v, ok := l.Get(k); !ok || v != k || k != int64(i+128) {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if _, ok := l.Get(0); ok {
+		t.Fatalf("should be evicted")
+	}
+	if !l.Remove(200) || l.Remove(200) || l.Contains(200) {
+		t.Fatalf("200 should have been removed once")
+	}
+	if v, ok := l.Peek(201); !ok || v != int64(201) {
+		t.Fatalf("bad peek: %v %v", v, ok)
+	}
+
+	l.Get(128)
+	if k, _, ok := l.GetOldest(); !ok || k != 129 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+	if k, _, ok := l.RemoveOldest(); !ok || k != 129 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+	if evicted := l.Resize(10); evicted != 116 || l.Len() != 10 {
+		t.Fatalf("bad resize: %v %v", evicted, l.Len())
+	}
+
+	l.Purge()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("should contain nothing")
+	}
+}
@@ -2,24 +2,95 @@ package simplelru
 
 import (
 	"container/list"
+	"encoding/gob"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
 )
 
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback func(key interface{}, value interface{})
 
+// ErrMaxSize is returned by ResizeChecked when the requested size exceeds
+// the maximum size of the cache.
+var ErrMaxSize = errors.New("size exceeds the maximum size")
+
 // LRU implements a non-thread safe fixed size LRU cache
 type LRU struct {
-	size      int
-	evictList *list.List
-	items     map[interface{}]*list.Element
-	onEvict   EvictCallback
+	size       int
+	maxSize    int
+	evictList  *list.List
+	items      map[interface{}]*list.Element
+	onEvict    EvictCallback
+	seen       *hyperLogLog
+	countHits  bool
+	trackMeta  bool
+	frozen     bool
+	positional bool
+	insertFrac float64
+	hits       uint64
+	misses     uint64
+	adds       uint64
+	evictions  uint64
 }
 
 // entry is used to hold a value in the evictList
 type entry struct {
 	key   interface{}
 	value interface{}
+	hits  int
+	dirty bool
+	meta  *entryMeta
+}
+
+// entryMeta holds the access times of an entry, once tracked.
+type entryMeta struct {
+	added, accessed time.Time
+}
+
+// EntryInfo is an entry along with its metadata, as returned by PeekEntry.
+type EntryInfo struct {
+	Key   interface{}
+	Value interface{}
+
+	// Added is when the key was added, and Accessed when it was last
+	// added or hit by Get. Both are zero for entries added before
+	// metadata tracking was enabled.
+	Added    time.Time
+	Accessed time.Time
+
+	// Hits is the number of Get hits since access counting was enabled
+	// or last reset.
+	Hits int
+}
+
+// KeyCount is a key along with the number of times it was accessed.
+type KeyCount struct {
+	Key   interface{}
+	Count int
+}
+
+// Entry is a key/value pair returned by bulk operations and snapshots.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Stats is a snapshot of the usage counters and occupancy of a cache.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Adds      uint64
+	Evictions uint64
+	Len       int
+	Cap       int
+
+	// HitRatio is Hits over the total number of lookups, or 0 if
+	// there were none.
+	HitRatio float64
 }
 
 // NewLRU constructs an LRU of the given size
@@ -36,28 +107,74 @@ func NewLRU(size int, onEvict EvictCallback) (*LRU, error) {
 	return c, nil
 }
 
-// Purge is used to completely clear the cache.
+// NewLRUWithMax constructs an LRU of the given size that can never be
+// resized beyond maxSize. Resize requests above maxSize are clamped to it;
+// ResizeChecked reports them.
+func NewLRUWithMax(size, maxSize int, onEvict EvictCallback) (*LRU, error) {
+	if size > maxSize {
+		return nil, errors.New("size must not exceed the maximum size")
+	}
+	c, err := NewLRU(size, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.maxSize = maxSize
+	return c, nil
+}
+
+// Purge is used to completely clear the cache. The eviction callback
+// is invoked for each entry from oldest to newest.
 func (c *LRU) Purge() {
-	for k, v := range c.items {
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if c.onEvict != nil {
+			c.onEvict(kv.key, kv.value)
+		}
+		delete(c.items, kv.key)
+	}
+	c.evictList.Init()
+}
+
+// PurgeReverse is used to completely clear the cache like Purge, but
+// invokes the eviction callback for each entry from newest to oldest.
+func (c *LRU) PurgeReverse() {
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		kv := ent.Value.(*entry)
 		if c.onEvict != nil {
-			c.onEvict(k, v.Value.(*entry).value)
+			c.onEvict(kv.key, kv.value)
 		}
-		delete(c.items, k)
+		delete(c.items, kv.key)
 	}
 	c.evictList.Init()
 }
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
 func (c *LRU) Add(key, value interface{}) (evicted bool) {
+	c.adds++
 	// Check for existing item
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
 		ent.Value.(*entry).value = value
+		if meta := ent.Value.(*entry).meta; meta != nil {
+			meta.accessed = time.Now()
+		}
 		return false
 	}
 
 	// Add new item
-	ent := &entry{key, value}
+	if c.seen != nil {
+		c.seen.add(HashKey(key))
+	}
+	ent := c.newEntry(key, value)
+	if c.positional {
+		// Make room first so that the new entry is not the one evicted
+		evict := c.evictList.Len() >= c.size
+		if evict {
+			c.removeOldest()
+		}
+		c.items[key] = c.insertAt(ent)
+		return evict
+	}
 	entry := c.evictList.PushFront(ent)
 	c.items[key] = entry
 
@@ -69,18 +186,105 @@ func (c *LRU) Add(key, value interface{}) (evicted bool) {
 	return evict
 }
 
+// GetOrCompute looks up a key's value from the cache, updating its
+// "recently used"-ness, and otherwise computes the value with fn and adds
+// it. Nothing is added if fn returns an error, which is returned as is.
+func (c *LRU) GetOrCompute(key interface{}, fn func(key interface{}) (interface{}, error)) (value interface{}, err error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	if value, err = fn(key); err != nil {
+		return nil, err
+	}
+	c.Add(key, value)
+	return value, nil
+}
+
+// AddWithEviction adds a value to the cache like Add, also returning the
+// key and value of the entry evicted to make room, if any.
+func (c *LRU) AddWithEviction(key, value interface{}) (evicted bool, evictedKey, evictedValue interface{}) {
+	if _, ok := c.items[key]; !ok && c.evictList.Len() >= c.size {
+		if ent := c.evictList.Back(); ent != nil {
+			kv := ent.Value.(*entry)
+			evictedKey, evictedValue = kv.key, kv.value
+		}
+	}
+	if !c.Add(key, value) {
+		return false, nil, nil
+	}
+	return true, evictedKey, evictedValue
+}
+
+// SetInsertPosition sets where Add places new keys in the recency order,
+// as a fraction of the current length counted from the oldest entry: 0
+// inserts as the oldest, 0.5 in the middle and 1, the default, as the
+// newest. Fractions outside of [0, 1] are clamped. The new key has
+// floor(frac*Len()) entries older than itself, Len() being the length once
+// room was made for it. New keys must then be hit by Get to become the
+// newest, which gives a degree of scan resistance. Updating an existing key
+// still makes it the newest. Inserting elsewhere than at either end walks
+// the recency list, so Add takes time linear in the length.
+func (c *LRU) SetInsertPosition(frac float64) {
+	if frac < 0 {
+		frac = 0
+	}
+	c.positional = frac < 1
+	c.insertFrac = frac
+}
+
+// insertAt links an entry at the configured insert position.
+func (c *LRU) insertAt(ent *entry) *list.Element {
+	n := c.evictList.Len()
+	older := int(c.insertFrac * float64(n))
+	switch {
+	case older >= n:
+		return c.evictList.PushFront(ent)
+	case older == 0:
+		return c.evictList.PushBack(ent)
+	case older <= n/2:
+		mark := c.evictList.Back()
+		for i := 1; i < older; i++ {
+			mark = mark.Prev()
+		}
+		return c.evictList.InsertBefore(ent, mark)
+	default:
+		mark := c.evictList.Front()
+		for i := n; i > older; i-- {
+			mark = mark.Next()
+		}
+		return c.evictList.InsertBefore(ent, mark)
+	}
+}
+
 // Get looks up a key's value from the cache.
 func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
 	if ent, ok := c.items[key]; ok {
-		c.evictList.MoveToFront(ent)
+		if !c.frozen {
+			c.evictList.MoveToFront(ent)
+		}
 		if ent.Value.(*entry) == nil {
 			return nil, false
 		}
+		if c.countHits {
+			ent.Value.(*entry).hits++
+		}
+		if meta := ent.Value.(*entry).meta; meta != nil {
+			meta.accessed = time.Now()
+		}
+		c.hits++
 		return ent.Value.(*entry).value, true
 	}
+	c.misses++
 	return
 }
 
+// SetFrozenRecency controls whether Get updates the "recently used"-ness
+// of keys. While frozen, Get behaves like Peek apart from counting lookups,
+// and entries are ordered by their last Add only.
+func (c *LRU) SetFrozenRecency(frozen bool) {
+	c.frozen = frozen
+}
+
 // Contains checks if a key is in the cache, without updating the recent-ness
 // or deleting it for being stale.
 func (c *LRU) Contains(key interface{}) (ok bool) {
@@ -98,6 +302,73 @@ func (c *LRU) Peek(key interface{}) (value interface{}, ok bool) {
 	return nil, ok
 }
 
+// PeekWithRank returns the key value along with its position in the
+// eviction order, without updating the "recently used"-ness of the key.
+// Rank 0 is the oldest entry, the next one to be evicted.
+func (c *LRU) PeekWithRank(key interface{}) (value interface{}, rank int, ok bool) {
+	ent, ok := c.items[key]
+	if !ok {
+		return nil, 0, false
+	}
+	for e := c.evictList.Back(); e != ent; e = e.Prev() {
+		rank++
+	}
+	return ent.Value.(*entry).value, rank, true
+}
+
+// Swap exchanges the values of two keys without updating their
+// "recently used"-ness. Returns false, leaving the cache unchanged,
+// unless both keys are contained.
+func (c *LRU) Swap(key1, key2 interface{}) bool {
+	ent1, ok1 := c.items[key1]
+	ent2, ok2 := c.items[key2]
+	if !ok1 || !ok2 {
+		return false
+	}
+	kv1, kv2 := ent1.Value.(*entry), ent2.Value.(*entry)
+	kv1.value, kv2.value = kv2.value, kv1.value
+	return true
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating the
+// recent-ness, and if not, adds the value. Returns whether found and
+// whether an eviction occurred.
+func (c *LRU) ContainsOrAdd(key, value interface{}) (ok, evicted bool) {
+	if c.Contains(key) {
+		return true, false
+	}
+	return false, c.Add(key, value)
+}
+
+// PeekOrAdd checks if a key is in the cache without updating the
+// recent-ness, and if not, adds the value. Returns the previous value if
+// found, whether found and whether an eviction occurred.
+func (c *LRU) PeekOrAdd(key, value interface{}) (previous interface{}, ok, evicted bool) {
+	if previous, ok = c.Peek(key); ok {
+		return previous, true, false
+	}
+	return nil, false, c.Add(key, value)
+}
+
+// Increment adds delta to the int64 value stored for key, treating a
+// missing key as zero, stores the result and updates the key's "recently
+// used"-ness. Returns the new value, or false, leaving the cache unchanged,
+// if the key holds a value that is not an int64.
+func (c *LRU) Increment(key interface{}, delta int64) (value int64, ok bool) {
+	if ent, found := c.items[key]; found {
+		e := ent.Value.(*entry)
+		n, isInt := e.value.(int64)
+		if !isInt {
+			return 0, false
+		}
+		c.evictList.MoveToFront(ent)
+		e.value = n + delta
+		return n + delta, true
+	}
+	c.Add(key, delta)
+	return delta, true
+}
+
 // Remove removes the provided key from the cache, returning if the
 // key was contained.
 func (c *LRU) Remove(key interface{}) (present bool) {
@@ -108,6 +379,73 @@ func (c *LRU) Remove(key interface{}) (present bool) {
 	return false
 }
 
+// RemoveMany removes the provided keys from the cache, returning the
+// removed entries. Keys not contained in the cache are skipped.
+func (c *LRU) RemoveMany(keys []interface{}) []Entry {
+	var removed []Entry
+	for _, key := range keys {
+		if ent, ok := c.items[key]; ok {
+			kv := ent.Value.(*entry)
+			removed = append(removed, Entry{kv.key, kv.value})
+			c.removeElement(ent)
+		}
+	}
+	return removed
+}
+
+// RetainKeys removes every entry whose key is not among the provided keys,
+// returning the number of entries removed.
+func (c *LRU) RetainKeys(keys []interface{}) int {
+	retain := make(map[interface{}]struct{}, len(keys))
+	for _, key := range keys {
+		retain[key] = struct{}{}
+	}
+	removed := 0
+	for ent := c.evictList.Back(); ent != nil; {
+		prev := ent.Prev()
+		if _, ok := retain[ent.Value.(*entry).key]; !ok {
+			c.removeElement(ent)
+			removed++
+		}
+		ent = prev
+	}
+	return removed
+}
+
+// RemoveMatch removes every entry whose key satisfies match, scanning the
+// keys once from oldest to newest, and returns the number removed. The
+// match function must not call back into the cache.
+func (c *LRU) RemoveMatch(match func(key interface{}) bool) int {
+	return c.RemoveFunc(func(key, _ interface{}) bool {
+		return match(key)
+	})
+}
+
+// RemoveFunc removes every entry whose key and value satisfy match,
+// scanning the entries once from oldest to newest, and returns the number
+// removed. The match function must not call back into the cache.
+func (c *LRU) RemoveFunc(match func(key, value interface{}) bool) int {
+	removed := 0
+	for ent := c.evictList.Back(); ent != nil; {
+		prev := ent.Prev()
+		if kv := ent.Value.(*entry); match(kv.key, kv.value) {
+			c.removeElement(ent)
+			removed++
+		}
+		ent = prev
+	}
+	return removed
+}
+
+// RemovePrefix removes every entry whose key is a string starting with
+// prefix, returning the number removed. Keys of other types are kept.
+func (c *LRU) RemovePrefix(prefix string) int {
+	return c.RemoveMatch(func(key interface{}) bool {
+		s, ok := key.(string)
+		return ok && strings.HasPrefix(s, prefix)
+	})
+}
+
 // RemoveOldest removes the oldest item from the cache.
 func (c *LRU) RemoveOldest() (key, value interface{}, ok bool) {
 	ent := c.evictList.Back()
@@ -119,6 +457,23 @@ func (c *LRU) RemoveOldest() (key, value interface{}, ok bool) {
 	return nil, nil, false
 }
 
+// DrainOldest removes up to n of the oldest entries from the cache without
+// invoking the eviction callback, returning them from oldest to newest.
+func (c *LRU) DrainOldest(n int) []Entry {
+	if n > c.Len() {
+		n = c.Len()
+	}
+	if n <= 0 {
+		return nil
+	}
+	drained := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		kv := c.unlinkElement(c.evictList.Back())
+		drained = append(drained, Entry{kv.key, kv.value})
+	}
+	return drained
+}
+
 // GetOldest returns the oldest entry
 func (c *LRU) GetOldest() (key, value interface{}, ok bool) {
 	ent := c.evictList.Back()
@@ -129,15 +484,93 @@ func (c *LRU) GetOldest() (key, value interface{}, ok bool) {
 	return nil, nil, false
 }
 
+// LeastValuable returns the entry with the lowest score without removing it
+// or updating its "recently used"-ness. Ties go to the oldest entry. The
+// score function must not call back into the cache.
+func (c *LRU) LeastValuable(score func(key, value interface{}) int64) (key, value interface{}, ok bool) {
+	var least int64
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if s := score(kv.key, kv.value); !ok || s < least {
+			key, value, least, ok = kv.key, kv.value, s, true
+		}
+	}
+	return key, value, ok
+}
+
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (c *LRU) Keys() []interface{} {
-	keys := make([]interface{}, len(c.items))
-	i := 0
+	return c.AppendKeys(make([]interface{}, 0, len(c.items)))
+}
+
+// AppendKeys appends the keys in the cache, from oldest to newest, to dst
+// and returns the extended slice. It does not allocate if dst has enough
+// capacity.
+func (c *LRU) AppendKeys(dst []interface{}) []interface{} {
 	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
-		keys[i] = ent.Value.(*entry).key
-		i++
+		dst = append(dst, ent.Value.(*entry).key)
+	}
+	return dst
+}
+
+// Items returns a snapshot of the entries in the cache, from oldest to
+// newest. The returned slice is owned by the caller.
+func (c *LRU) Items() []Entry {
+	items := make([]Entry, 0, len(c.items))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		items = append(items, Entry{kv.key, kv.value})
+	}
+	return items
+}
+
+// Snapshot writes the entries of the cache to w, from oldest to newest,
+// using encoding/gob, so that Restore can rebuild the cache with the same
+// recency order, for instance on restart. Keys and values must be encodable
+// by gob, with any named or struct types registered using gob.Register,
+// otherwise an error is returned.
+func (c *LRU) Snapshot(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(c.Items())
+}
+
+// Restore reads entries written by Snapshot from r and adds them in order,
+// so that the last entry written is the most recently used. Existing
+// entries are kept, older than the restored ones; call Purge first to
+// replace them. If the snapshot holds more entries than the cache size,
+// the oldest are evicted. Nothing is added if the snapshot cannot be
+// decoded.
+func (c *LRU) Restore(r io.Reader) error {
+	var items []Entry
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return err
+	}
+	for _, e := range items {
+		c.Add(e.Key, e.Value)
+	}
+	return nil
+}
+
+// Range calls fn for each entry in the cache, from oldest to newest,
+// without updating the "recently used"-ness of the keys and without
+// copying the entries. It stops early if fn returns false. The function
+// must not call back into the cache.
+func (c *LRU) Range(fn func(key, value interface{}) bool) {
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// MapValues replaces the value of every entry with the result of transform,
+// from oldest to newest, without updating the "recently used"-ness of the
+// keys. The transform must not call back into the cache.
+func (c *LRU) MapValues(transform func(key, value interface{}) interface{}) {
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		kv.value = transform(kv.key, kv.value)
 	}
-	return keys
 }
 
 // Len returns the number of items in the cache.
@@ -145,8 +578,15 @@ func (c *LRU) Len() int {
 	return c.evictList.Len()
 }
 
-// Resize changes the cache size.
+// Cap returns the maximum number of items the cache can hold.
+func (c *LRU) Cap() int {
+	return c.size
+}
+
+// Resize changes the cache size. If the cache was constructed with a
+// maximum size, larger sizes are clamped to it.
 func (c *LRU) Resize(size int) (evicted int) {
+	size = c.clampSize(size)
 	diff := c.Len() - size
 	if diff < 0 {
 		diff = 0
@@ -158,20 +598,239 @@ func (c *LRU) Resize(size int) (evicted int) {
 	return diff
 }
 
+// ResizeChecked changes the cache size like Resize, but reports a size
+// above the maximum size: the cache is resized to the maximum size and
+// ErrMaxSize is returned, along with the size applied.
+func (c *LRU) ResizeChecked(size int) (newSize, evicted int, err error) {
+	newSize = c.clampSize(size)
+	if newSize != size {
+		err = ErrMaxSize
+	}
+	return newSize, c.Resize(newSize), err
+}
+
+// ResizeToFill changes the cache size to ratio times the current number of
+// items, with a minimum size of 1 and subject to the maximum size, if any.
+// A ratio below 1 evicts the oldest items.
+func (c *LRU) ResizeToFill(ratio float64) (newSize, evicted int) {
+	newSize = int(float64(c.Len()) * ratio)
+	if newSize < 1 {
+		newSize = 1
+	}
+	newSize = c.clampSize(newSize)
+	return newSize, c.Resize(newSize)
+}
+
+// clampSize limits size to the maximum size, if any.
+func (c *LRU) clampSize(size int) int {
+	if c.maxSize > 0 && size > c.maxSize {
+		return c.maxSize
+	}
+	return size
+}
+
+// EnableCardinalityTracking starts counting the distinct keys added to the
+// cache, as reported by CardinalitySeen. Keys currently cached are counted
+// immediately. Keys are counted by hash in a fixed-size HyperLogLog sketch,
+// so memory does not grow with the number of distinct keys and evicted
+// keys are not retained.
+func (c *LRU) EnableCardinalityTracking() {
+	if c.seen != nil {
+		return
+	}
+	c.seen = new(hyperLogLog)
+	for k := range c.items {
+		c.seen.add(HashKey(k))
+	}
+}
+
+// CardinalitySeen returns the estimated number of distinct keys added
+// since cardinality tracking was enabled, or 0 if it is disabled. The
+// estimate is within a few percent of the exact count.
+func (c *LRU) CardinalitySeen() uint64 {
+	if c.seen == nil {
+		return 0
+	}
+	return c.seen.estimate()
+}
+
+// EnableAccessCounts starts counting the hits of each entry, as reported
+// by TopAccessed. Counts are kept per entry and are dropped on eviction.
+func (c *LRU) EnableAccessCounts() {
+	c.countHits = true
+}
+
+// EnableEntryMetadata starts recording when each entry is added and last
+// accessed, and counting its hits, as reported by PeekEntry. Recording
+// reads the clock on every Add and Get hit, and takes extra memory for
+// each entry added from then on.
+func (c *LRU) EnableEntryMetadata() {
+	c.trackMeta = true
+	c.countHits = true
+}
+
+// newEntry creates an entry, with metadata if tracked.
+func (c *LRU) newEntry(key, value interface{}) *entry {
+	ent := &entry{key: key, value: value}
+	if c.trackMeta {
+		now := time.Now()
+		ent.meta = &entryMeta{added: now, accessed: now}
+	}
+	return ent
+}
+
+// PeekEntry returns the entry of a key along with its metadata, without
+// updating the "recently used"-ness of the key.
+func (c *LRU) PeekEntry(key interface{}) (info EntryInfo, ok bool) {
+	ent, ok := c.items[key]
+	if !ok {
+		return EntryInfo{}, false
+	}
+	kv := ent.Value.(*entry)
+	info = EntryInfo{Key: kv.key, Value: kv.value, Hits: kv.hits}
+	if kv.meta != nil {
+		info.Added, info.Accessed = kv.meta.added, kv.meta.accessed
+	}
+	return info, true
+}
+
+// ResetAccessCounts zeroes the hit count of every entry.
+func (c *LRU) ResetAccessCounts() {
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		ent.Value.(*entry).hits = 0
+	}
+}
+
+// TopAccessed returns up to n keys with the highest hit counts since access
+// counting was enabled or last reset, most accessed first. Entries that were
+// never hit are omitted, and nil is returned if n is zero or less.
+func (c *LRU) TopAccessed(n int) []KeyCount {
+	if n <= 0 {
+		return nil
+	}
+	var counts []KeyCount
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		if kv := ent.Value.(*entry); kv.hits > 0 {
+			counts = append(counts, KeyCount{kv.key, kv.hits})
+		}
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Count > counts[j].Count
+	})
+	if n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// MarkDirty flags the entry of the provided key as having unflushed
+// modifications, returning if the key was contained. The flag survives
+// later adds of the same key until it is cleared or the entry is removed.
+func (c *LRU) MarkDirty(key interface{}) bool {
+	if ent, ok := c.items[key]; ok {
+		ent.Value.(*entry).dirty = true
+		return true
+	}
+	return false
+}
+
+// ClearDirty clears the dirty flag of the provided key, typically once its
+// value has been flushed, returning if the entry was dirty.
+func (c *LRU) ClearDirty(key interface{}) bool {
+	if ent, ok := c.items[key]; ok {
+		kv := ent.Value.(*entry)
+		wasDirty := kv.dirty
+		kv.dirty = false
+		return wasDirty
+	}
+	return false
+}
+
+// DirtyKeys returns a slice of the keys flagged dirty, from oldest to newest.
+func (c *LRU) DirtyKeys() []interface{} {
+	var keys []interface{}
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		if kv := ent.Value.(*entry); kv.dirty {
+			keys = append(keys, kv.key)
+		}
+	}
+	return keys
+}
+
+// Stats returns the lookup, add and eviction counters of the cache along
+// with its current length and capacity. Only Get counts as a lookup, and
+// only removals made to free capacity count as evictions.
+func (c *LRU) Stats() Stats {
+	st := Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Adds:      c.adds,
+		Evictions: c.evictions,
+		Len:       c.Len(),
+		Cap:       c.size,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		st.HitRatio = float64(c.hits) / float64(lookups)
+	}
+	return st
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *LRU) ResetStats() {
+	c.hits, c.misses, c.adds, c.evictions = 0, 0, 0, 0
+}
+
+// Check verifies the internal consistency of the cache: that the recency
+// list and the key index hold the same entries, that no key appears twice
+// and that the length does not exceed the size. Returns an error describing
+// the first violation found. It is meant as a debugging aid, e.g. as a
+// post-condition in tests, and takes time linear in the length.
+func (c *LRU) Check() error {
+	if n, m := c.evictList.Len(), len(c.items); n != m {
+		return fmt.Errorf("list length %d does not match index length %d", n, m)
+	}
+	seen := make(map[interface{}]struct{}, len(c.items))
+	for e := c.evictList.Back(); e != nil; e = e.Prev() {
+		kv, ok := e.Value.(*entry)
+		if !ok || kv == nil {
+			return fmt.Errorf("list element holds no entry")
+		}
+		if _, dup := seen[kv.key]; dup {
+			return fmt.Errorf("duplicate key %v", kv.key)
+		}
+		seen[kv.key] = struct{}{}
+		if c.items[kv.key] != e {
+			return fmt.Errorf("key %v is not indexed to its list element", kv.key)
+		}
+	}
+	if n := c.evictList.Len(); n > c.size {
+		return fmt.Errorf("length %d exceeds size %d", n, c.size)
+	}
+	return nil
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *LRU) removeOldest() {
 	ent := c.evictList.Back()
 	if ent != nil {
+		c.evictions++
 		c.removeElement(ent)
 	}
 }
 
 // removeElement is used to remove a given list element from the cache
 func (c *LRU) removeElement(e *list.Element) {
-	c.evictList.Remove(e)
-	kv := e.Value.(*entry)
-	delete(c.items, kv.key)
+	kv := c.unlinkElement(e)
 	if c.onEvict != nil {
 		c.onEvict(kv.key, kv.value)
 	}
 }
+
+// unlinkElement removes a given list element from the cache without
+// invoking the eviction callback.
+func (c *LRU) unlinkElement(e *list.Element) *entry {
+	c.evictList.Remove(e)
+	kv := e.Value.(*entry)
+	delete(c.items, kv.key)
+	return kv
+}
@@ -1,6 +1,29 @@
 package simplelru
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func BenchmarkLRU_AppendKeys(b *testing.B) {
+	l, err := NewLRU(1024, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Add(i, i)
+	}
+	keys := make([]interface{}, 0, 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		keys = l.AppendKeys(keys[:0])
+	}
+}
 
 func TestLRU(t *testing.T) {
 	evictCounter := 0
@@ -204,3 +227,934 @@ func TestLRU_Resize(t *testing.T) {
 		t.Errorf("Cache should have contained 2 elements")
 	}
 }
+
+// Test that Purge evicts entries from oldest to newest
+func TestLRU_PurgeOrder(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+
+	l.Purge()
+	expected := []interface{}{1, 2, 3, 4, 5, 6, 7, 0}
+	if len(evicted) != len(expected) {
+		t.Fatalf("bad evict count: %v", len(evicted))
+	}
+	for i, k := range expected {
+		if evicted[i] != k {
+			t.Fatalf("bad evict order: %v", evicted)
+		}
+	}
+}
+
+// Test that ResizeToFill sizes relative to the current length
+func TestLRU_ResizeToFill(t *testing.T) {
+	l, err := NewLRU(100, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+
+	size, evicted := l.ResizeToFill(1.5)
+	if size != 15 || evicted != 0 {
+		t.Fatalf("bad: %v %v", size, evicted)
+	}
+
+	size, evicted = l.ResizeToFill(0.5)
+	if size != 5 || evicted != 5 {
+		t.Fatalf("bad: %v %v", size, evicted)
+	}
+	if l.Contains(4) || !l.Contains(5) {
+		t.Fatalf("oldest entries should have been evicted")
+	}
+
+	l.Purge()
+	size, evicted = l.ResizeToFill(2)
+	if size != 1 || evicted != 0 {
+		t.Fatalf("bad: %v %v", size, evicted)
+	}
+}
+
+// Test that CardinalitySeen counts distinct keys, including evicted ones
+func TestLRU_CardinalitySeen(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(-1, -1)
+	if n := l.CardinalitySeen(); n != 0 {
+		t.Fatalf("tracking should be disabled: %v", n)
+	}
+
+	l.EnableCardinalityTracking()
+	if n := l.CardinalitySeen(); n != 1 {
+		t.Fatalf("bad cardinality: %v", n)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+		l.Add(i, i)
+	}
+	l.Remove(9)
+	if n := l.CardinalitySeen(); n != 11 {
+		t.Fatalf("bad cardinality: %v", n)
+	}
+
+	// Large cardinalities are estimated in fixed memory
+	for i := 0; i < 100000; i++ {
+		l.Add(fmt.Sprintf("key-%d", i), i)
+		l.Add(i+10, i)
+	}
+	if n := l.CardinalitySeen(); n < 190000 || n > 210000 {
+		t.Fatalf("bad cardinality: %v", n)
+	}
+}
+
+// Test that PeekWithRank reports the position without updating recent-ness
+func TestLRU_PeekWithRank(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(0)
+
+	for k, want := range map[int]int{1: 0, 2: 1, 3: 2, 0: 3} {
+		v, rank, ok := l.PeekWithRank(k)
+		if !ok || v != k*10 || rank != want {
+			t.Fatalf("bad rank for %v: %v %v %v", k, v, rank, ok)
+		}
+	}
+	if _, _, ok := l.PeekWithRank(4); ok {
+		t.Fatalf("should not be contained")
+	}
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Fatalf("PeekWithRank should not have updated recent-ness: %v", k)
+	}
+}
+
+// Test that RemoveMany removes present keys and returns their entries
+func TestLRU_RemoveMany(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter++
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i*10)
+	}
+
+	removed := l.RemoveMany([]interface{}{1, 3, 9, 5})
+	if len(removed) != 3 {
+		t.Fatalf("bad removed count: %v", len(removed))
+	}
+	for i, k := range []int{1, 3, 5} {
+		if removed[i].Key != k || removed[i].Value != k*10 {
+			t.Fatalf("bad entry: %v", removed[i])
+		}
+		if l.Contains(k) {
+			t.Fatalf("%v should have been removed", k)
+		}
+	}
+	if evictCounter != 3 || l.Len() != 5 {
+		t.Fatalf("bad: %v %v", evictCounter, l.Len())
+	}
+}
+
+// Test that TopAccessed ranks entries by hit count
+func TestLRU_TopAccessed(t *testing.T) {
+	l, err := NewLRU(8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	l.Get(1)
+	if top := l.TopAccessed(3); len(top) != 0 {
+		t.Fatalf("counting should be disabled: %v", top)
+	}
+
+	l.EnableAccessCounts()
+	for i := 0; i < 8; i++ {
+		for j := 0; j < i%4; j++ {
+			l.Get(i)
+		}
+	}
+	top := l.TopAccessed(3)
+	if len(top) != 3 {
+		t.Fatalf("bad len: %v", len(top))
+	}
+	if top[0].Key != 3 || top[0].Count != 3 || top[1].Key != 7 || top[2].Count != 2 {
+		t.Fatalf("bad top keys: %v", top)
+	}
+	if top := l.TopAccessed(100); len(top) != 6 {
+		t.Fatalf("bad len: %v", len(top))
+	}
+	if top := l.TopAccessed(0); top != nil {
+		t.Fatalf("bad top keys: %v", top)
+	}
+	if top := l.TopAccessed(-1); top != nil {
+		t.Fatalf("bad top keys: %v", top)
+	}
+
+	l.ResetAccessCounts()
+	if top := l.TopAccessed(3); len(top) != 0 {
+		t.Fatalf("counts should have been reset: %v", top)
+	}
+}
+
+// Test that RetainKeys removes every key not in the provided set
+func TestLRU_RetainKeys(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter++
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+
+	removed := l.RetainKeys([]interface{}{2, 5, 9})
+	if removed != 6 || evictCounter != 6 {
+		t.Fatalf("bad: %v %v", removed, evictCounter)
+	}
+	keys := l.Keys()
+	if len(keys) != 2 || keys[0] != 2 || keys[1] != 5 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+// Test that PurgeReverse evicts entries from newest to oldest
+func TestLRU_PurgeReverseOrder(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	l.Get(7)
+	l.Get(3)
+
+	l.PurgeReverse()
+	expected := []interface{}{3, 7, 6, 5, 4, 2, 1, 0}
+	if len(evicted) != len(expected) {
+		t.Fatalf("bad evict count: %v", len(evicted))
+	}
+	for i, k := range expected {
+		if evicted[i] != k {
+			t.Fatalf("bad evict order: %v", evicted)
+		}
+	}
+	if l.Len() != 0 || l.Contains(3) {
+		t.Fatalf("cache should be empty")
+	}
+}
+
+// Test that DrainOldest removes the oldest entries without callbacks
+func TestLRU_DrainOldest(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter++
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i*10)
+	}
+
+	drained := l.DrainOldest(3)
+	if len(drained) != 3 || evictCounter != 0 {
+		t.Fatalf("bad: %v %v", len(drained), evictCounter)
+	}
+	for i, e := range drained {
+		if e.Key != i || e.Value != i*10 || l.Contains(i) {
+			t.Fatalf("bad entry: %v", e)
+		}
+	}
+
+	if drained := l.DrainOldest(100); len(drained) != 5 || l.Len() != 0 {
+		t.Fatalf("bad: %v %v", len(drained), l.Len())
+	}
+	if drained := l.DrainOldest(1); drained != nil {
+		t.Fatalf("should drain nothing: %v", drained)
+	}
+}
+
+// Test that dirty flags are tracked per entry
+func TestLRU_Dirty(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+
+	if !l.MarkDirty(2) || !l.MarkDirty(0) || l.MarkDirty(9) {
+		t.Fatalf("bad MarkDirty result")
+	}
+	l.Add(0, 10)
+	keys := l.DirtyKeys()
+	if len(keys) != 2 || keys[0] != 2 || keys[1] != 0 {
+		t.Fatalf("bad dirty keys: %v", keys)
+	}
+
+	if !l.ClearDirty(2) || l.ClearDirty(2) || l.ClearDirty(1) {
+		t.Fatalf("bad ClearDirty result")
+	}
+	l.Remove(0)
+	l.Add(0, 0)
+	if keys := l.DirtyKeys(); len(keys) != 0 {
+		t.Fatalf("bad dirty keys: %v", keys)
+	}
+}
+
+// Test that Resize is clamped to the maximum size
+func TestLRU_ResizeWithMax(t *testing.T) {
+	if _, err := NewLRUWithMax(10, 5, nil); err == nil {
+		t.Fatalf("should reject a size above the maximum")
+	}
+	l, err := NewLRUWithMax(2, 4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Resize(100)
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 4 {
+		t.Fatalf("size should have been clamped: %v", l.Len())
+	}
+
+	if size, _ := l.ResizeToFill(2); size != 4 {
+		t.Fatalf("size should have been clamped: %v", size)
+	}
+	if size, evicted := l.ResizeToFill(0.5); size != 2 || evicted != 2 {
+		t.Fatalf("bad: %v %v", size, evicted)
+	}
+
+	// ResizeChecked reports the clamping
+	if size, evicted, err := l.ResizeChecked(3); size != 3 || evicted != 0 || err != nil {
+		t.Fatalf("bad: %v %v %v", size, evicted, err)
+	}
+	l.Add(10, 10)
+	if size, evicted, err := l.ResizeChecked(8); size != 4 || evicted != 0 || err != ErrMaxSize {
+		t.Fatalf("bad: %v %v %v", size, evicted, err)
+	}
+	if l.Cap() != 4 {
+		t.Fatalf("bad cap: %v", l.Cap())
+	}
+	if size, evicted, err := l.ResizeChecked(1); size != 1 || evicted != 2 || err != nil {
+		t.Fatalf("bad: %v %v %v", size, evicted, err)
+	}
+}
+
+// Test that AppendKeys appends keys from oldest to newest without allocating
+func TestLRU_AppendKeys(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Get(1)
+
+	keys := l.AppendKeys([]interface{}{"x"})
+	expected := []interface{}{"x", 0, 2, 3, 1}
+	if len(keys) != len(expected) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	for i, k := range expected {
+		if keys[i] != k {
+			t.Fatalf("bad keys: %v", keys)
+		}
+	}
+
+	buf := make([]interface{}, 0, 4)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = l.AppendKeys(buf[:0])
+	})
+	if allocs != 0 {
+		t.Fatalf("should not allocate: %v", allocs)
+	}
+}
+
+// Test that Items returns entries from oldest to newest
+func TestLRU_Items(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if items := l.Items(); len(items) != 0 {
+		t.Fatalf("bad items: %v", items)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(0)
+
+	items := l.Items()
+	expected := []int{1, 2, 3, 0}
+	if len(items) != len(expected) {
+		t.Fatalf("bad items: %v", items)
+	}
+	for i, k := range expected {
+		if items[i].Key != k || items[i].Value != k*10 {
+			t.Fatalf("bad items: %v", items)
+		}
+	}
+
+	items[0].Value = -1
+	if v, _ := l.Peek(1); v != 10 {
+		t.Fatalf("snapshot should be owned by the caller")
+	}
+}
+
+// Test that Stats reports lookups, evictions and occupancy
+func TestLRU_Stats(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if st := l.Stats(); st != (Stats{Cap: 4}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	for i := 0; i < 6; i++ {
+		l.Get(i)
+	}
+	l.Remove(5)
+	l.Resize(2)
+
+	st := l.Stats()
+	expected := Stats{Hits: 4, Misses: 2, Adds: 6, Evictions: 3, Len: 2, Cap: 2, HitRatio: 4.0 / 6.0}
+	if st != expected {
+		t.Fatalf("bad stats: %+v", st)
+	}
+
+	l.ResetStats()
+	if st := l.Stats(); st != (Stats{Len: 2, Cap: 2}) {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+// Test that Get does not update recent-ness while recency is frozen
+func TestLRU_FrozenRecency(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetFrozenRecency(true)
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	l.Add(3, 3)
+	if l.Contains(1) {
+		t.Fatalf("Get should not have updated recent-ness of 1")
+	}
+
+	l.Add(2, 2)
+	l.Add(4, 4)
+	if !l.Contains(2) || l.Contains(3) {
+		t.Fatalf("Add should still update recent-ness")
+	}
+
+	l.SetFrozenRecency(false)
+	l.Get(2)
+	l.Add(5, 5)
+	if !l.Contains(2) || l.Contains(4) {
+		t.Fatalf("Get should update recent-ness again")
+	}
+}
+
+// Test that MapValues transforms values in place
+func TestLRU_MapValues(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.Get(0)
+
+	l.MapValues(func(k, v interface{}) interface{} {
+		return v.(int) * 10
+	})
+	for i := 0; i < 4; i++ {
+		if v, _ := l.Peek(i); v != i*10 {
+			t.Fatalf("bad value for %v: %v", i, v)
+		}
+	}
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Fatalf("MapValues should not have updated recent-ness: %v", k)
+	}
+}
+
+// Test that Swap exchanges values without updating recent-ness
+func TestLRU_Swap(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i*10)
+	}
+
+	if l.Swap(0, 9) || l.Swap(9, 0) {
+		t.Fatalf("should not swap with a missing key")
+	}
+	if v, _ := l.Peek(0); v != 0 {
+		t.Fatalf("failed swap should not change values: %v", v)
+	}
+
+	if !l.Swap(0, 3) {
+		t.Fatalf("should swap contained keys")
+	}
+	v0, _ := l.Peek(0)
+	v3, _ := l.Peek(3)
+	if v0 != 30 || v3 != 0 {
+		t.Fatalf("bad values: %v %v", v0, v3)
+	}
+	if k, _, _ := l.GetOldest(); k != 0 {
+		t.Fatalf("Swap should not have updated recent-ness: %v", k)
+	}
+}
+
+// Test that RemovePrefix only removes matching string keys
+func TestLRU_RemovePrefix(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter++
+	}
+	l, err := NewLRU(8, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, k := range []interface{}{"users:1", "orders:1", "users:2", 3, "users"} {
+		l.Add(k, k)
+	}
+
+	if removed := l.RemovePrefix("users:"); removed != 2 || evictCounter != 2 {
+		t.Fatalf("bad: %v %v", removed, evictCounter)
+	}
+	keys := l.Keys()
+	if len(keys) != 3 || keys[0] != "orders:1" || keys[1] != 3 || keys[2] != "users" {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	removed := l.RemoveMatch(func(k interface{}) bool {
+		_, ok := k.(int)
+		return ok
+	})
+	if removed != 1 || l.Contains(3) {
+		t.Fatalf("bad: %v", removed)
+	}
+
+	removed = l.RemoveFunc(func(k, v interface{}) bool {
+		return v == "users"
+	})
+	if removed != 1 || l.Contains("users") || l.Len() != 1 {
+		t.Fatalf("bad: %v", removed)
+	}
+}
+
+// Test that LeastValuable returns the lowest scored entry
+func TestLRU_LeastValuable(t *testing.T) {
+	l, err := NewLRU(8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	score := func(k, v interface{}) int64 {
+		return int64(len(v.(string)))
+	}
+	if _, _, ok := l.LeastValuable(score); ok {
+		t.Fatalf("should find nothing")
+	}
+
+	l.Add(1, "aaa")
+	l.Add(2, "b")
+	l.Add(3, "cc")
+	l.Add(4, "d")
+	k, v, ok := l.LeastValuable(score)
+	if !ok || k != 2 || v != "b" {
+		t.Fatalf("bad: %v %v %v", k, v, ok)
+	}
+	if l.Len() != 4 {
+		t.Fatalf("should not remove the entry")
+	}
+}
+
+// Test that Increment treats missing keys as zero and rejects non-int64 values
+func TestLRU_Increment(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := l.Increment(1, 3); !ok || v != 3 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	l.Add(2, int64(10))
+	if v, ok := l.Increment(1, -1); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	// 1 is now the most recently used, so adding 3 evicts 2
+	l.Add(3, "x")
+	if l.Contains(2) {
+		t.Fatalf("Increment should update recent-ness")
+	}
+	if _, ok := l.Increment(3, 1); ok {
+		t.Fatalf("should reject non-int64 value")
+	}
+	if v, _ := l.Peek(3); v != "x" {
+		t.Fatalf("bad value: %v", v)
+	}
+}
+
+// Test that Check detects broken invariants
+func TestLRU_Check(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+	}
+	l.Get(3)
+	l.Remove(4)
+	if err := l.Check(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Index an element under a second key
+	l.items[100] = l.items[3]
+	if err := l.Check(); err == nil {
+		t.Fatalf("should detect length mismatch")
+	}
+	delete(l.items, 100)
+
+	// Duplicate a key in the list
+	l.evictList.PushFront(&entry{key: 3, value: 3})
+	l.items[99] = l.evictList.Front()
+	if err := l.Check(); err == nil || err.Error() != "duplicate key 3" {
+		t.Fatalf("bad err: %v", err)
+	}
+	l.evictList.Remove(l.evictList.Front())
+	delete(l.items, 99)
+
+	// Exceed the size
+	l.size = 2
+	if err := l.Check(); err == nil || err.Error() != "length 3 exceeds size 2" {
+		t.Fatalf("bad err: %v", err)
+	}
+}
+
+// Test that SetInsertPosition places new keys at the requested position
+func TestLRU_SetInsertPosition(t *testing.T) {
+	l, err := NewLRU(5, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.SetInsertPosition(0.5)
+	l.Add(4, 4)
+	// floor(0.5*4) = 2 entries are older than 4
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{0, 1, 4, 2, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	// The cache is full: 0 is evicted first, then 5 has floor(0.5*4) older
+	if !l.Add(5, 5) {
+		t.Fatalf("should evict")
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{1, 4, 5, 2, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	l.SetInsertPosition(0.9)
+	l.Add(6, 6)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{4, 5, 2, 6, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	l.SetInsertPosition(-1)
+	l.Add(7, 7)
+	if k, _, _ := l.GetOldest(); k != 7 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+	// Updating a key still makes it the newest
+	l.Add(7, 7)
+	if keys := l.Keys(); keys[len(keys)-1] != 7 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	l.SetInsertPosition(1)
+	l.Add(8, 8)
+	if keys := l.Keys(); keys[len(keys)-1] != 8 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if err := l.Check(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func equalKeys(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Test that AddWithEviction returns the evicted entry
+func TestLRU_AddWithEviction(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, pos := range []float64{1, 0.5} {
+		l.Purge()
+		l.SetInsertPosition(pos)
+		if evicted, k, v := l.AddWithEviction(1, "a"); evicted || k != nil || v != nil {
+			t.Fatalf("bad: %v %v %v", evicted, k, v)
+		}
+		l.AddWithEviction(2, "b")
+		if evicted, k, v := l.AddWithEviction(2, "c"); evicted || k != nil || v != nil {
+			t.Fatalf("bad: %v %v %v", evicted, k, v)
+		}
+		if evicted, k, v := l.AddWithEviction(3, "d"); !evicted || k != 1 || v != "a" {
+			t.Fatalf("bad: %v %v %v", evicted, k, v)
+		}
+	}
+}
+
+// Test that keys of distinct named types do not collide
+func TestLRU_NamedKeyTypes(t *testing.T) {
+	type UserID int64
+	type OrderID int64
+
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(UserID(1), "user")
+	l.Add(OrderID(1), "order")
+	if l.Len() != 2 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if v, ok := l.Get(UserID(1)); !ok || v != "user" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if v, ok := l.Get(OrderID(1)); !ok || v != "order" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if l.Contains(int64(1)) {
+		t.Fatalf("untyped key should not match")
+	}
+	l.Remove(OrderID(1))
+	if !l.Contains(UserID(1)) {
+		t.Fatalf("removing an OrderID should keep the UserID")
+	}
+}
+
+// Test that GetOrCompute only computes missing keys
+func TestLRU_GetOrCompute(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	calls := 0
+	fn := func(k interface{}) (interface{}, error) {
+		calls++
+		if k == "bad" {
+			return nil, errors.New("bad key")
+		}
+		return k, nil
+	}
+	if v, err := l.GetOrCompute(1, fn); err != nil || v != 1 {
+		t.Fatalf("bad: %v %v", v, err)
+	}
+	if v, err := l.GetOrCompute(1, fn); err != nil || v != 1 || calls != 1 {
+		t.Fatalf("bad: %v %v %v", v, err, calls)
+	}
+	if _, err := l.GetOrCompute("bad", fn); err == nil {
+		t.Fatalf("should fail")
+	}
+	if l.Contains("bad") {
+		t.Fatalf("errors should not be cached")
+	}
+}
+
+// Test that Range visits entries from oldest to newest and stops early
+func TestLRU_Range(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(0)
+
+	var keys []interface{}
+	l.Range(func(k, v interface{}) bool {
+		if v != k.(int)*10 {
+			t.Fatalf("bad value for %v: %v", k, v)
+		}
+		keys = append(keys, k)
+		return true
+	})
+	if !equalKeys(keys, []interface{}{1, 2, 3, 0}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	n := 0
+	l.Range(func(k, v interface{}) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Fatalf("should stop early: %v", n)
+	}
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Fatalf("Range should not update recent-ness")
+	}
+}
+
+// Test that ContainsOrAdd and PeekOrAdd only add missing keys
+func TestLRU_ContainsOrAdd(t *testing.T) {
+	l, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ok, evicted := l.ContainsOrAdd(1, 1); ok || evicted {
+		t.Fatalf("bad: %v %v", ok, evicted)
+	}
+	if ok, evicted := l.ContainsOrAdd(1, 10); !ok || evicted {
+		t.Fatalf("bad: %v %v", ok, evicted)
+	}
+	if prev, ok, evicted := l.PeekOrAdd(2, 2); prev != nil || ok || evicted {
+		t.Fatalf("bad: %v %v %v", prev, ok, evicted)
+	}
+	if prev, ok, evicted := l.PeekOrAdd(1, 10); prev != 1 || !ok || evicted {
+		t.Fatalf("bad: %v %v %v", prev, ok, evicted)
+	}
+	// Neither updated the recent-ness of 1
+	if ok, evicted := l.ContainsOrAdd(3, 3); ok || !evicted {
+		t.Fatalf("bad: %v %v", ok, evicted)
+	}
+	if l.Contains(1) {
+		t.Fatalf("1 should have been evicted")
+	}
+}
+
+// Test that PeekEntry reports entry metadata once enabled
+func TestLRU_PeekEntry(t *testing.T) {
+	l, err := NewLRU(4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.EnableEntryMetadata()
+	before := time.Now()
+	l.Add(2, 2)
+	l.Get(2)
+	l.Get(2)
+	l.Get(1)
+
+	info, ok := l.PeekEntry(2)
+	if !ok || info.Key != 2 || info.Value != 2 || info.Hits != 2 {
+		t.Fatalf("bad entry: %+v", info)
+	}
+	if info.Added.Before(before) || info.Accessed.Before(info.Added) {
+		t.Fatalf("bad times: %+v", info)
+	}
+
+	// 1 predates tracking
+	info, ok = l.PeekEntry(1)
+	if !ok || info.Hits != 1 || !info.Added.IsZero() || !info.Accessed.IsZero() {
+		t.Fatalf("bad entry: %+v", info)
+	}
+	if _, ok := l.PeekEntry(3); ok {
+		t.Fatalf("should not contain 3")
+	}
+	// PeekEntry does not update recency
+	if k, _, _ := l.GetOldest(); k != 2 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+}
+
+// Test that Restore rebuilds a snapshot with the same recency order
+func TestLRU_Snapshot(t *testing.T) {
+	l, err := NewLRU(3, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, "a")
+	l.Add(2, "b")
+	l.Add(3, "c")
+	l.Get(1)
+
+	var buf bytes.Buffer
+	if err := l.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r, err := NewLRU(2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := r.Restore(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The oldest entry does not fit
+	if keys := r.Keys(); len(keys) != 2 || keys[0] != 3 || keys[1] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if v, _ := r.Peek(1); v != "a" {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	if err := r.Restore(bytes.NewReader([]byte("junk"))); err == nil {
+		t.Fatalf("should fail on invalid input")
+	}
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
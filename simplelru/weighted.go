@@ -0,0 +1,132 @@
+package simplelru
+
+import "errors"
+
+// CostFunc returns the cost of an entry, such as the size of its value in
+// bytes, for a WeightedLRU.
+type CostFunc func(key, value interface{}) int64
+
+// WeightedLRU implements a non-thread safe LRU cache bounded by the total
+// cost of its entries instead of their number.
+type WeightedLRU struct {
+	lru     *LRU
+	cost    CostFunc
+	maxCost int64
+	total   int64
+	onEvict EvictCallback
+}
+
+// weighted is the value stored in the underlying LRU.
+type weighted struct {
+	value interface{}
+	cost  int64
+}
+
+// NewWeightedLRU constructs a WeightedLRU whose entries cost at most
+// maxCost in total, the cost of each entry being computed by cost when it
+// is added.
+func NewWeightedLRU(maxCost int64, cost CostFunc, onEvict EvictCallback) (*WeightedLRU, error) {
+	if maxCost <= 0 {
+		return nil, errors.New("must provide a positive maximum cost")
+	}
+	if cost == nil {
+		return nil, errors.New("must provide a cost function")
+	}
+	c := &WeightedLRU{
+		cost:    cost,
+		maxCost: maxCost,
+		onEvict: onEvict,
+	}
+	// The underlying LRU is bounded by cost only
+	lru, err := NewLRU(int(^uint(0)>>1), c.onEvicted)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	return c, nil
+}
+
+// onEvicted releases the cost of an entry leaving the cache.
+func (c *WeightedLRU) onEvicted(k, v interface{}) {
+	w := v.(*weighted)
+	c.total -= w.cost
+	if c.onEvict != nil {
+		c.onEvict(k, w.value)
+	}
+}
+
+// Add adds a value to the cache, evicting the oldest entries until the
+// total cost fits within the maximum. Returns whether an eviction occurred
+// and whether the value was added: a value costing more than the maximum
+// on its own is rejected, and any previous value of the key is removed.
+func (c *WeightedLRU) Add(key, value interface{}) (evicted, ok bool) {
+	cost := c.cost(key, value)
+	if cost > c.maxCost {
+		c.lru.Remove(key)
+		return false, false
+	}
+	if v, ok := c.lru.Peek(key); ok {
+		c.total -= v.(*weighted).cost
+	}
+	c.lru.Add(key, &weighted{value: value, cost: cost})
+	c.total += cost
+	for c.total > c.maxCost {
+		c.lru.removeOldest()
+		evicted = true
+	}
+	return evicted, true
+}
+
+// Get looks up a key's value from the cache.
+func (c *WeightedLRU) Get(key interface{}) (value interface{}, ok bool) {
+	if v, ok := c.lru.Get(key); ok {
+		return v.(*weighted).value, true
+	}
+	return nil, false
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *WeightedLRU) Peek(key interface{}) (value interface{}, ok bool) {
+	if v, ok := c.lru.Peek(key); ok {
+		return v.(*weighted).value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness.
+func (c *WeightedLRU) Contains(key interface{}) bool {
+	return c.lru.Contains(key)
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *WeightedLRU) Remove(key interface{}) (present bool) {
+	return c.lru.Remove(key)
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *WeightedLRU) Keys() []interface{} {
+	return c.lru.Keys()
+}
+
+// Len returns the number of items in the cache.
+func (c *WeightedLRU) Len() int {
+	return c.lru.Len()
+}
+
+// Cost returns the total cost of the entries in the cache.
+func (c *WeightedLRU) Cost() int64 {
+	return c.total
+}
+
+// MaxCost returns the maximum total cost of the entries in the cache.
+func (c *WeightedLRU) MaxCost() int64 {
+	return c.maxCost
+}
+
+// Purge is used to completely clear the cache.
+func (c *WeightedLRU) Purge() {
+	c.lru.Purge()
+}
@@ -0,0 +1,62 @@
+package simplelru
+
+import "testing"
+
+func TestWeightedLRU(t *testing.T) {
+	var evicted []interface{}
+	l, err := NewWeightedLRU(10, func(k, v interface{}) int64 {
+		return int64(len(v.(string)))
+	}, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, "aaaa")
+	l.Add(2, "bbb")
+	l.Add(3, "cc")
+	l.Get(1)
+	if l.Cost() != 9 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+	// 2 then 3 are the oldest and must go to fit 5 more
+	if evicted, ok := l.Add(4, "ddddd"); !evicted || !ok {
+		t.Fatalf("bad: %v %v", evicted, ok)
+	}
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 3 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if l.Cost() != 9 || l.Len() != 2 {
+		t.Fatalf("bad cost: %v len: %v", l.Cost(), l.Len())
+	}
+
+	// Updating a key accounts for its new cost
+	l.Add(1, "a")
+	if l.Cost() != 6 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	// Over budget values are rejected and replace nothing
+	if evicted, ok := l.Add(4, "xxxxxxxxxxx"); evicted || ok {
+		t.Fatalf("bad: %v %v", evicted, ok)
+	}
+	if l.Contains(4) || l.Cost() != 1 {
+		t.Fatalf("rejected key should be removed, cost: %v", l.Cost())
+	}
+
+	if v, ok := l.Peek(1); !ok || v != "a" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	l.Remove(1)
+	if l.Cost() != 0 || l.Len() != 0 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+
+	if _, err := NewWeightedLRU(0, l.cost, nil); err == nil {
+		t.Fatalf("should reject zero max cost")
+	}
+	if _, err := NewWeightedLRU(1, nil, nil); err == nil {
+		t.Fatalf("should reject nil cost func")
+	}
+}
@@ -0,0 +1,108 @@
+package lru
+
+// sketchDepth is the number of rows of counters in a sketch.
+const sketchDepth = 4
+
+// sketchMax is the maximum value of a counter, as the 4 bit counters of
+// TinyLFU saturate.
+const sketchMax = 15
+
+// sketch is a count-min sketch estimating the access frequency of keys
+// in little memory, fronted by a doorkeeper bloom filter so that keys
+// accessed once do not take up counters. Counters are halved and the
+// doorkeeper cleared after a sample of accesses ten times the cache size,
+// so that estimates reflect recent popularity.
+type sketch struct {
+	rows  [sketchDepth][]uint8
+	door  []uint64
+	mask  uint64
+	adds  int
+	reset int
+}
+
+// newSketch creates a sketch sized for a cache of the given size.
+func newSketch(size int) *sketch {
+	width := 16
+	for width < size {
+		width <<= 1
+	}
+	s := &sketch{
+		door:  make([]uint64, (width+63)/64),
+		mask:  uint64(width - 1),
+		reset: 10 * size,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the i-th counter or doorkeeper index of a hash, by double
+// hashing.
+func (s *sketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+// admitted reports whether the doorkeeper has seen a hash, marking it
+// seen.
+func (s *sketch) admitted(h uint64) bool {
+	seen := true
+	for i := sketchDepth; i < sketchDepth+2; i++ {
+		idx := s.index(h, i)
+		if s.door[idx/64]&(1<<(idx%64)) == 0 {
+			s.door[idx/64] |= 1 << (idx % 64)
+			seen = false
+		}
+	}
+	return seen
+}
+
+// increment counts an access to a key.
+func (s *sketch) increment(key interface{}) {
+	h := HashKey(key)
+	if s.admitted(h) {
+		for i := range s.rows {
+			if idx := s.index(h, i); s.rows[i][idx] < sketchMax {
+				s.rows[i][idx]++
+			}
+		}
+	}
+	s.adds++
+	if s.adds >= s.reset {
+		s.age()
+	}
+}
+
+// estimate returns the estimated access frequency of a key.
+func (s *sketch) estimate(key interface{}) int {
+	h := HashKey(key)
+	min := uint8(sketchMax)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	freq := int(min)
+	door := true
+	for i := sketchDepth; i < sketchDepth+2; i++ {
+		idx := s.index(h, i)
+		door = door && s.door[idx/64]&(1<<(idx%64)) != 0
+	}
+	if door {
+		freq++
+	}
+	return freq
+}
+
+// age halves the counters and clears the doorkeeper.
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	for i := range s.door {
+		s.door[i] = 0
+	}
+	s.adds /= 2
+}
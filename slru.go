@@ -0,0 +1,293 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// DefaultSLRUProtectedRatio is the ratio of the SLRU cache dedicated to
+// entries accessed at least twice.
+const DefaultSLRUProtectedRatio = 0.80
+
+// SLRUCache is a thread-safe fixed size Segmented LRU cache. New entries
+// land in a probation segment and are only promoted to a protected segment
+// when accessed again, so that a scan of entries used once cannot evict
+// the entries used repeatedly. Entries overflowing the protected segment
+// are demoted back to probation, and evictions come from probation first.
+// SLRU is lighter weight than 2Q, which additionally tracks the keys
+// recently evicted, but does not tell apart re-accessed evicted entries
+// from new ones.
+type SLRUCache struct {
+	slru  *slru
+	stats stats
+	lock  sync.RWMutex
+}
+
+// slru is a non-thread safe Segmented LRU, shared by SLRUCache and
+// TinyLFUCache.
+type slru struct {
+	size           int
+	protectedSize  int
+	protectedRatio float64
+	probation      *simplelru.LRU
+	protected      *simplelru.LRU
+}
+
+// newSLRU creates an slru of the given size, a protectedRatio of which is
+// dedicated to the protected segment.
+func newSLRU(size int, protectedRatio float64) (*slru, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	if protectedRatio < 0.0 || protectedRatio > 1.0 {
+		return nil, fmt.Errorf("invalid protected ratio")
+	}
+	// Probation may take up the whole cache while protected is empty
+	probation, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	protected, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &slru{
+		probation:      probation,
+		protected:      protected,
+		protectedRatio: protectedRatio,
+	}
+	s.resize(size)
+	return s, nil
+}
+
+// resize changes the size, keeping the protected ratio, and returns the
+// number of entries evicted. Entries overflowing the protected segment are
+// demoted to probation first.
+func (s *slru) resize(size int) (evicted int) {
+	s.size = size
+	s.protectedSize = int(float64(size) * s.protectedRatio)
+	for s.protected.Len() > s.protectedSize {
+		k, v, _ := s.protected.RemoveOldest()
+		s.probation.Add(k, v)
+	}
+	for s.len() > size {
+		s.evict()
+		evicted++
+	}
+	s.probation.Resize(size)
+	s.protected.Resize(size)
+	return evicted
+}
+
+// get looks up a key's value, promoting it to the protected segment.
+func (s *slru) get(key interface{}) (value interface{}, ok bool) {
+	if val, ok := s.protected.Get(key); ok {
+		return val, ok
+	}
+	if val, ok := s.probation.Peek(key); ok {
+		s.promote(key, val)
+		return val, ok
+	}
+	return nil, false
+}
+
+// promote moves a key from probation to protected, demoting the oldest
+// protected entry back to probation if protected overflows.
+func (s *slru) promote(key, value interface{}) {
+	if s.protectedSize == 0 {
+		s.probation.Add(key, value)
+		return
+	}
+	s.probation.Remove(key)
+	s.protected.Add(key, value)
+	if s.protected.Len() > s.protectedSize {
+		k, v, _ := s.protected.RemoveOldest()
+		s.probation.Add(k, v)
+	}
+}
+
+// add adds a value, treating an existing key as accessed again. Returns
+// the entry evicted to make room, if any.
+func (s *slru) add(key, value interface{}) (evictedKey, evictedValue interface{}, evicted bool) {
+	if s.protected.Contains(key) {
+		s.protected.Add(key, value)
+		return nil, nil, false
+	}
+	if s.probation.Contains(key) {
+		s.promote(key, value)
+		return nil, nil, false
+	}
+	if s.len() >= s.size {
+		evictedKey, evictedValue, evicted = s.evict()
+	}
+	s.probation.Add(key, value)
+	return evictedKey, evictedValue, evicted
+}
+
+// victim returns the key evict would remove next.
+func (s *slru) victim() (key interface{}, ok bool) {
+	if key, _, ok := s.probation.GetOldest(); ok {
+		return key, ok
+	}
+	key, _, ok = s.protected.GetOldest()
+	return key, ok
+}
+
+// evict removes the oldest probation entry, or the oldest protected entry
+// if probation is empty.
+func (s *slru) evict() (key, value interface{}, ok bool) {
+	if key, value, ok := s.probation.RemoveOldest(); ok {
+		return key, value, ok
+	}
+	return s.protected.RemoveOldest()
+}
+
+func (s *slru) peek(key interface{}) (value interface{}, ok bool) {
+	if val, ok := s.protected.Peek(key); ok {
+		return val, ok
+	}
+	return s.probation.Peek(key)
+}
+
+func (s *slru) contains(key interface{}) bool {
+	return s.protected.Contains(key) || s.probation.Contains(key)
+}
+
+func (s *slru) remove(key interface{}) bool {
+	return s.protected.Remove(key) || s.probation.Remove(key)
+}
+
+// keys returns the keys in eviction order: probation then protected, each
+// from oldest to newest.
+func (s *slru) keys() []interface{} {
+	return append(s.probation.Keys(), s.protected.Keys()...)
+}
+
+func (s *slru) len() int {
+	return s.probation.Len() + s.protected.Len()
+}
+
+func (s *slru) purge() {
+	s.probation.Purge()
+	s.protected.Purge()
+}
+
+// NewSLRU creates an SLRUCache of the given size using the default
+// protected ratio.
+func NewSLRU(size int) (*SLRUCache, error) {
+	return NewSLRUParams(size, DefaultSLRUProtectedRatio)
+}
+
+// NewSLRUParams creates an SLRUCache of the given size, a protectedRatio of
+// which is dedicated to the protected segment, the rest to probation. A
+// protectedRatio of zero makes an LRU cache.
+func NewSLRUParams(size int, protectedRatio float64) (*SLRUCache, error) {
+	s, err := newSLRU(size, protectedRatio)
+	if err != nil {
+		return nil, err
+	}
+	return &SLRUCache{slru: s}, nil
+}
+
+// Get looks up a key's value from the cache, promoting it to the protected
+// segment.
+func (c *SLRUCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, ok = c.slru.get(key)
+	if ok {
+		c.stats.hits++
+	} else {
+		c.stats.misses++
+	}
+	return value, ok
+}
+
+// Add adds a value to the cache on probation, or updates it and promotes
+// it to the protected segment if already contained. Returns true if an
+// eviction occurred.
+func (c *SLRUCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.adds++
+	_, _, evicted = c.slru.add(key, value)
+	if evicted {
+		c.stats.evictions++
+	}
+	return evicted
+}
+
+// Peek is used to inspect the cache value of a key without promoting it.
+func (c *SLRUCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.slru.peek(key)
+}
+
+// Contains is used to check if the cache contains a key without promoting
+// it.
+func (c *SLRUCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.slru.contains(key)
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *SLRUCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.slru.remove(key)
+}
+
+// Keys returns a slice of the keys in the cache, in eviction order: the
+// probation segment then the protected segment, each from oldest to newest.
+func (c *SLRUCache) Keys() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.slru.keys()
+}
+
+// Len returns the number of items in the cache.
+func (c *SLRUCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.slru.len()
+}
+
+// Resize changes the cache size, keeping the protected ratio, and returns
+// the number of entries evicted. Sizes below 1 are raised to 1.
+func (c *SLRUCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	evicted = c.slru.resize(size)
+	c.stats.evictions += uint64(evicted)
+	return evicted
+}
+
+// Purge is used to completely clear the cache.
+func (c *SLRUCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.slru.purge()
+}
+
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity.
+func (c *SLRUCache) Stats() simplelru.Stats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stats.snapshot(c.slru.len(), c.slru.size)
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *SLRUCache) ResetStats() {
+	c.lock.Lock()
+	c.stats = stats{}
+	c.lock.Unlock()
+}
@@ -0,0 +1,116 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkSLRU_Rand(b *testing.B) {
+	l, err := NewSLRU(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestSLRU(t *testing.T) {
+	l, err := NewSLRUParams(4, 0.5)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	// Promote 0 and 1, then 2 which demotes 0
+	l.Get(0)
+	l.Get(1)
+	l.Get(2)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{3, 0, 1, 2}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// A scan only evicts from probation
+	for i := 10; i < 20; i++ {
+		if !l.Add(i, i) {
+			t.Fatalf("should evict")
+		}
+	}
+	if !l.Contains(1) || !l.Contains(2) || l.Contains(0) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+
+	// Adding an existing key promotes it
+	l.Add(19, 190)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{18, 1, 2, 19}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if v, ok := l.Peek(19); !ok || v != 190 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	if !l.Remove(2) || l.Remove(2) || l.Len() != 3 {
+		t.Fatalf("bad remove")
+	}
+	if st := l.Stats(); st.Hits != 3 || st.Adds != 15 || st.Evictions != 10 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+	l.Purge()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// Test that a protected ratio of zero makes an LRU cache
+func TestSLRU_NoProtected(t *testing.T) {
+	l, err := NewSLRUParams(2, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(1)
+	l.Add(3, 3)
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{1, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	if _, err := NewSLRUParams(2, 1.5); err == nil {
+		t.Fatalf("should reject ratio")
+	}
+	if _, err := NewSLRU(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
+
+func equalKeys(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
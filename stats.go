@@ -0,0 +1,25 @@
+package lru
+
+import "github.com/hashicorp/golang-lru/simplelru"
+
+// stats holds the usage counters of the caches other than Cache, which
+// gets them from the underlying LRU.
+type stats struct {
+	hits, misses, adds, evictions uint64
+}
+
+// snapshot returns the counters along with the given length and capacity.
+func (s *stats) snapshot(len, cap int) simplelru.Stats {
+	st := simplelru.Stats{
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Adds:      s.adds,
+		Evictions: s.evictions,
+		Len:       len,
+		Cap:       cap,
+	}
+	if lookups := st.Hits + st.Misses; lookups > 0 {
+		st.HitRatio = float64(st.Hits) / float64(lookups)
+	}
+	return st
+}
@@ -0,0 +1,139 @@
+package lru
+
+// tagIndex maps tags to the keys carrying them, and keys to their tags.
+type tagIndex struct {
+	keys map[string]map[interface{}]struct{}
+	tags map[interface{}][]string
+
+	// limit is the number of tagged keys above which pruneTags runs
+	limit int
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		keys: make(map[string]map[interface{}]struct{}),
+		tags: make(map[interface{}][]string),
+	}
+}
+
+// set replaces the tags of a key.
+func (t *tagIndex) set(key interface{}, tags []string) {
+	t.remove(key)
+	if len(tags) == 0 {
+		return
+	}
+	t.tags[key] = append([]string(nil), tags...)
+	for _, tag := range tags {
+		keys := t.keys[tag]
+		if keys == nil {
+			keys = make(map[interface{}]struct{})
+			t.keys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// remove drops the tags of a key.
+func (t *tagIndex) remove(key interface{}) {
+	for _, tag := range t.tags[key] {
+		delete(t.keys[tag], key)
+		if len(t.keys[tag]) == 0 {
+			delete(t.keys, tag)
+		}
+	}
+	delete(t.tags, key)
+}
+
+// AddWithTags adds a value to the cache like Add, associating it with the
+// provided tags, so that InvalidateTag can drop every entry carrying one
+// of them, for instance when a parent object changes. The tags replace
+// those of an existing key, while Add keeps them. Tags are dropped along
+// with the entry. Returns true if an eviction occurred.
+func (c *Cache) AddWithTags(key, value interface{}, tags ...string) (evicted bool) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	c.overflowRemove(key)
+	evicted = c.lru.Add(key, value)
+	if c.tags == nil {
+		c.tags = newTagIndex()
+	}
+	c.tags.set(key, tags)
+	c.publish(EventAdd, key, value)
+	c.shadowAdd(key, value)
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return evicted
+}
+
+// InvalidateTag removes every entry carrying the provided tag, including
+// from the overflow cache, returning the number of entries removed from
+// this cache. The eviction callback is invoked for each of them, as with
+// Remove, in no particular order.
+func (c *Cache) InvalidateTag(tag string) (removed int) {
+	c.lock.Lock()
+	before := c.lru.Len()
+	if c.tags != nil {
+		keys := make([]interface{}, 0, len(c.tags.keys[tag]))
+		for key := range c.tags.keys[tag] {
+			keys = append(keys, key)
+		}
+		c.removing = true
+		removed = len(c.lru.RemoveMany(keys))
+		c.removing = false
+		// The keys left are those moved to the overflow
+		for key := range c.tags.keys[tag] {
+			c.overflowRemove(key)
+			c.tags.remove(key)
+		}
+	}
+	cb := c.takeCallbacks(before)
+	c.lock.Unlock()
+	cb.invoke(c)
+	return removed
+}
+
+// pruneTags drops the tags of the keys held by neither the cache nor its
+// overflow, once the tagged keys outnumber twice those kept by the last
+// pruning, so that entries the overflow evicted on its own do not leak in
+// the index. It must be called with the lock held.
+func (c *Cache) pruneTags() {
+	if len(c.tags.tags) <= c.tags.limit {
+		return
+	}
+	for key := range c.tags.tags {
+		if c.lru.Contains(key) {
+			continue
+		}
+		if oc := c.overflowCache(); oc != nil {
+			if _, ok := oc.Peek(key); ok {
+				continue
+			}
+		}
+		c.tags.remove(key)
+	}
+	c.tags.limit = 2 * len(c.tags.tags)
+	if c.tags.limit < c.lru.Cap() {
+		c.tags.limit = c.lru.Cap()
+	}
+}
+
+// Tags returns the tags of a key, or nil if it has none or is held by
+// neither the cache nor its overflow.
+func (c *Cache) Tags(key interface{}) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.tags == nil || c.tags.tags[key] == nil {
+		return nil
+	}
+	if !c.lru.Contains(key) {
+		oc := c.overflowCache()
+		if oc == nil {
+			return nil
+		}
+		if _, ok := oc.Peek(key); !ok {
+			return nil
+		}
+	}
+	return append([]string(nil), c.tags.tags[key]...)
+}
@@ -0,0 +1,78 @@
+package lru
+
+import "testing"
+
+func TestLRU_InvalidateTag(t *testing.T) {
+	var evicted []interface{}
+	l, err := NewWithEvict(4, func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.AddWithTags("order:1", 1, "user:1")
+	l.AddWithTags("order:2", 2, "user:1", "user:2")
+	l.AddWithTags("order:3", 3, "user:2")
+	l.Add("order:4", 4)
+
+	if tags := l.Tags("order:2"); len(tags) != 2 || tags[0] != "user:1" || tags[1] != "user:2" {
+		t.Fatalf("bad tags: %v", tags)
+	}
+	if n := l.InvalidateTag("user:1"); n != 2 || len(evicted) != 2 {
+		t.Fatalf("bad invalidation: %v %v", n, evicted)
+	}
+	if l.Contains("order:1") || l.Contains("order:2") || l.Len() != 2 {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+	if n := l.InvalidateTag("user:1"); n != 0 {
+		t.Fatalf("bad invalidation: %v", n)
+	}
+
+	// Add keeps the tags, AddWithTags replaces them
+	l.Add("order:3", 30)
+	if tags := l.Tags("order:3"); len(tags) != 1 || tags[0] != "user:2" {
+		t.Fatalf("bad tags: %v", tags)
+	}
+	l.AddWithTags("order:3", 31, "user:3")
+	if n := l.InvalidateTag("user:2"); n != 0 {
+		t.Fatalf("bad invalidation: %v", n)
+	}
+
+	// Evicted entries lose their tags
+	l.AddWithTags("order:5", 5, "user:5")
+	l.Add("order:6", 6)
+	l.Add("order:7", 7)
+	l.Add("order:8", 8)
+	l.Add("order:9", 9)
+	if l.Contains("order:5") || l.Tags("order:5") != nil {
+		t.Fatalf("order:5 should be evicted")
+	}
+	if n := l.InvalidateTag("user:5"); n != 0 {
+		t.Fatalf("bad invalidation: %v", n)
+	}
+	if len(l.tags.keys) != 0 || len(l.tags.tags) != 0 {
+		t.Fatalf("tag index should be empty: %v %v", l.tags.keys, l.tags.tags)
+	}
+}
+
+// Test that drained entries lose their tags
+func TestCacheTags_DrainOldest(t *testing.T) {
+	l, err := New(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.AddWithTags("k", 1, "t")
+	if drained := l.DrainOldest(1); len(drained) != 1 {
+		t.Fatalf("bad drained: %v", drained)
+	}
+	l.Add("k", 2)
+	if tags := l.Tags("k"); tags != nil {
+		t.Fatalf("bad tags: %v", tags)
+	}
+	if n := l.InvalidateTag("t"); n != 0 || !l.Contains("k") {
+		t.Fatalf("untagged key should be kept: %v", n)
+	}
+	if len(l.tags.keys) != 0 || len(l.tags.tags) != 0 {
+		t.Fatalf("tag index should be empty: %v %v", l.tags.keys, l.tags.tags)
+	}
+}
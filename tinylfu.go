@@ -0,0 +1,235 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// DefaultTinyLFUWindowRatio is the ratio of the TinyLFU cache dedicated to
+// the admission window.
+const DefaultTinyLFUWindowRatio = 0.01
+
+// TinyLFUCache is a thread-safe fixed size W-TinyLFU cache. New entries
+// enter a small LRU window. Entries evicted from the window are only
+// admitted into the main SLRU cache if the TinyLFU frequency sketch
+// estimates that they are accessed more often than the entry the main cache
+// would evict in their place, otherwise they are dropped. This gives near
+// optimal hit ratios for skewed workloads, while the sketch only takes a
+// few bytes per entry. Lookups write to the sketch, so they take the lock
+// exclusively.
+type TinyLFUCache struct {
+	size        int
+	windowSize  int
+	windowRatio float64
+	window      *simplelru.LRU
+	main        *slru
+	sketch      *sketch
+	stats       stats
+	lock        sync.RWMutex
+}
+
+// NewTinyLFU creates a TinyLFUCache of the given size using the default
+// window ratio.
+func NewTinyLFU(size int) (*TinyLFUCache, error) {
+	return NewTinyLFUParams(size, DefaultTinyLFUWindowRatio)
+}
+
+// NewTinyLFUParams creates a TinyLFUCache of the given size, a windowRatio
+// of which is dedicated to the admission window, the rest to the main
+// cache. The window holds at least one entry when the cache holds two or
+// more. Larger windows favour recency over frequency.
+func NewTinyLFUParams(size int, windowRatio float64) (*TinyLFUCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size")
+	}
+	if windowRatio < 0.0 || windowRatio >= 1.0 {
+		return nil, fmt.Errorf("invalid window ratio")
+	}
+	windowSize := tinyLFUWindowSize(size, windowRatio)
+	window, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	main, err := newSLRU(size-windowSize, DefaultSLRUProtectedRatio)
+	if err != nil {
+		return nil, err
+	}
+	c := &TinyLFUCache{
+		size:        size,
+		windowSize:  windowSize,
+		windowRatio: windowRatio,
+		window:      window,
+		main:        main,
+		sketch:      newSketch(size),
+	}
+	return c, nil
+}
+
+// tinyLFUWindowSize returns the size of the window of a cache, holding at
+// least one entry when the cache holds two or more.
+func tinyLFUWindowSize(size int, windowRatio float64) int {
+	windowSize := int(float64(size) * windowRatio)
+	if windowSize == 0 && windowRatio > 0 && size > 1 {
+		windowSize = 1
+	}
+	return windowSize
+}
+
+// Get looks up a key's value from the cache, counting the access in the
+// frequency sketch even if the key is missing.
+func (c *TinyLFUCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sketch.increment(key)
+	if value, ok = c.window.Get(key); !ok {
+		value, ok = c.main.get(key)
+	}
+	if ok {
+		c.stats.hits++
+	} else {
+		c.stats.misses++
+	}
+	return value, ok
+}
+
+// Add adds a value to the cache, counting the access in the frequency
+// sketch. Returns true if an eviction occurred, which may be the rejection
+// of an entry leaving the window, possibly the one just added.
+func (c *TinyLFUCache) Add(key, value interface{}) (evicted bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.adds++
+	c.sketch.increment(key)
+	if c.window.Contains(key) {
+		c.window.Add(key, value)
+		return false
+	}
+	if c.main.contains(key) {
+		c.main.add(key, value)
+		return false
+	}
+
+	if c.windowSize > 0 {
+		c.window.Add(key, value)
+		if c.window.Len() <= c.windowSize {
+			return false
+		}
+		key, value, _ = c.window.RemoveOldest()
+	}
+	evicted = c.admit(key, value)
+	if evicted {
+		c.stats.evictions++
+	}
+	return evicted
+}
+
+// admit adds a candidate entry to the main cache if it has room, or if the
+// candidate is estimated to be accessed more often than the victim of the
+// main cache, which is then evicted. Returns true if either the victim or
+// the candidate was evicted.
+func (c *TinyLFUCache) admit(key, value interface{}) (evicted bool) {
+	if c.main.len() < c.main.size {
+		c.main.add(key, value)
+		return false
+	}
+	victim, _ := c.main.victim()
+	if c.sketch.estimate(key) > c.sketch.estimate(victim) {
+		c.main.add(key, value)
+	}
+	return true
+}
+
+// Peek is used to inspect the cache value of a key without updating its
+// recency or frequency.
+func (c *TinyLFUCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if val, ok := c.window.Peek(key); ok {
+		return val, ok
+	}
+	return c.main.peek(key)
+}
+
+// Contains is used to check if the cache contains a key without updating
+// its recency or frequency.
+func (c *TinyLFUCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.window.Contains(key) || c.main.contains(key)
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained. The frequency sketch keeps counting its past accesses.
+func (c *TinyLFUCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.window.Remove(key) || c.main.remove(key)
+}
+
+// Keys returns a slice of the keys in the cache: the main cache keys, in
+// SLRU eviction order, then the window keys from oldest to newest.
+func (c *TinyLFUCache) Keys() []interface{} {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append(c.main.keys(), c.window.Keys()...)
+}
+
+// Len returns the number of items in the cache.
+func (c *TinyLFUCache) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.window.Len() + c.main.len()
+}
+
+// Resize changes the cache size, keeping the window ratio, and returns the
+// number of entries evicted. Entries overflowing the window go through
+// admission into the main cache. The frequency sketch keeps its counters,
+// only the sample of accesses between agings follows the new size. Sizes
+// below 1 are raised to 1.
+func (c *TinyLFUCache) Resize(size int) (evicted int) {
+	if size < 1 {
+		size = 1
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.size = size
+	c.windowSize = tinyLFUWindowSize(size, c.windowRatio)
+	evicted = c.main.resize(size - c.windowSize)
+	for c.window.Len() > c.windowSize {
+		key, value, _ := c.window.RemoveOldest()
+		if c.admit(key, value) {
+			evicted++
+		}
+	}
+	c.window.Resize(size)
+	c.sketch.reset = 10 * size
+	c.stats.evictions += uint64(evicted)
+	return evicted
+}
+
+// Purge is used to completely clear the cache, including the frequency
+// sketch.
+func (c *TinyLFUCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.window.Purge()
+	c.main.purge()
+	c.sketch = newSketch(c.size)
+}
+
+// Stats returns a consistent snapshot of the lookup, add and eviction
+// counters of the cache along with its current length and capacity.
+func (c *TinyLFUCache) Stats() simplelru.Stats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.stats.snapshot(c.window.Len()+c.main.len(), c.size)
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *TinyLFUCache) ResetStats() {
+	c.lock.Lock()
+	c.stats = stats{}
+	c.lock.Unlock()
+}
@@ -0,0 +1,174 @@
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkTinyLFU_Rand(b *testing.B) {
+	l, err := NewTinyLFU(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkTinyLFU_StructKeys(b *testing.B) {
+	type key struct {
+		Tenant string
+		ID     int64
+	}
+	l, err := NewTinyLFU(8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]key, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = key{"tenant", rand.Int63() % 32768}
+	}
+
+	b.ResetTimer()
+
+	var hit, miss int
+	for i := 0; i < 2*b.N; i++ {
+		if i%2 == 0 {
+			l.Add(trace[i], trace[i])
+		} else {
+			if _, ok := l.Get(trace[i]); ok {
+				hit++
+			} else {
+				miss++
+			}
+		}
+	}
+	b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(100)
+	if f := s.estimate("a"); f != 0 {
+		t.Fatalf("bad estimate: %v", f)
+	}
+	// The first access only goes to the doorkeeper
+	s.increment("a")
+	if f := s.estimate("a"); f != 1 {
+		t.Fatalf("bad estimate: %v", f)
+	}
+	for i := 0; i < 20; i++ {
+		s.increment("a")
+	}
+	if f := s.estimate("a"); f != sketchMax+1 {
+		t.Fatalf("bad estimate: %v", f)
+	}
+
+	s.age()
+	if f := s.estimate("a"); f != sketchMax/2 {
+		t.Fatalf("bad estimate: %v", f)
+	}
+	if s.adds != 10 {
+		t.Fatalf("bad adds: %v", s.adds)
+	}
+}
+
+// Test that frequently used entries survive a scan
+func TestTinyLFU_Scan(t *testing.T) {
+	l, err := NewTinyLFU(100)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Add(i, i)
+	}
+	for n := 0; n < 3; n++ {
+		for i := 0; i < 50; i++ {
+			l.Get(i)
+		}
+	}
+
+	for i := 1000; i < 1500; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 100 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	for i := 0; i < 50; i++ {
+		if !l.Contains(i) {
+			t.Fatalf("should contain %d", i)
+		}
+	}
+	// The latest scanned key is in the window
+	if !l.Contains(1499) {
+		t.Fatalf("should contain 1499")
+	}
+	if st := l.Stats(); st.Evictions != 500 || st.Hits != 150 {
+		t.Fatalf("bad stats: %+v", st)
+	}
+}
+
+func TestTinyLFU(t *testing.T) {
+	l, err := NewTinyLFUParams(4, 0.25)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if l.Add(i, i) {
+			t.Fatalf("should not evict")
+		}
+	}
+	if keys := l.Keys(); !equalKeys(keys, []interface{}{0, 1, 2, 3}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	// 4 is as frequent as the victim 0, so it is rejected
+	if !l.Add(4, 4) || l.Contains(3) || !l.Contains(4) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+	// 3 was accessed more than 0 by now
+	l.Add(3, 3)
+	l.Add(5, 5)
+	if l.Contains(0) || !l.Contains(3) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+
+	if v, ok := l.Get(5); !ok || v != 5 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if v, ok := l.Peek(3); !ok || v != 3 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if !l.Remove(5) || l.Remove(5) || l.Len() != 3 {
+		t.Fatalf("bad remove")
+	}
+	l.Purge()
+	if l.Len() != 0 || l.sketch.estimate(3) != 0 {
+		t.Fatalf("bad purge")
+	}
+
+	if _, err := NewTinyLFUParams(4, 1); err == nil {
+		t.Fatalf("should reject ratio")
+	}
+	if _, err := NewTinyLFU(0); err == nil {
+		t.Fatalf("should reject size")
+	}
+}
@@ -0,0 +1,124 @@
+package lru
+
+import (
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// WeightedCache is a thread-safe LRU cache bounded by the total cost of
+// its entries instead of their number.
+type WeightedCache struct {
+	lru     *simplelru.WeightedLRU
+	onEvict simplelru.EvictCallback
+	evicted []simplelru.Entry
+	lock    sync.Mutex
+}
+
+// NewWeighted creates a WeightedCache whose entries cost at most maxCost
+// in total, the cost of each entry being computed by cost when it is
+// added.
+func NewWeighted(maxCost int64, cost simplelru.CostFunc) (*WeightedCache, error) {
+	return NewWeightedWithEvict(maxCost, cost, nil)
+}
+
+// NewWeightedWithEvict constructs a WeightedCache with the given eviction
+// callback, invoked outside of the lock for entries evicted or removed.
+func NewWeightedWithEvict(maxCost int64, cost simplelru.CostFunc, onEvicted simplelru.EvictCallback) (*WeightedCache, error) {
+	c := &WeightedCache{onEvict: onEvicted}
+	var onEvict simplelru.EvictCallback
+	if onEvicted != nil {
+		onEvict = c.onEvicted
+	}
+	lru, err := simplelru.NewWeightedLRU(maxCost, cost, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	return c, nil
+}
+
+// onEvicted buffers evicted entries for the eviction callback.
+// It is called with the lock held.
+func (c *WeightedCache) onEvicted(k, v interface{}) {
+	c.evicted = append(c.evicted, simplelru.Entry{Key: k, Value: v})
+}
+
+// unlock releases the lock and invokes the eviction callback for the
+// entries evicted while it was held.
+func (c *WeightedCache) unlock() {
+	evicted := c.evicted
+	c.evicted = nil
+	c.lock.Unlock()
+	for _, e := range evicted {
+		c.onEvict(e.Key, e.Value)
+	}
+}
+
+// Add adds a value to the cache, evicting the oldest entries until the
+// total cost fits within the maximum. Returns whether an eviction occurred
+// and whether the value was added. See simplelru.WeightedLRU.Add.
+func (c *WeightedCache) Add(key, value interface{}) (evicted, ok bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Add(key, value)
+}
+
+// Get looks up a key's value from the cache.
+func (c *WeightedCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Get(key)
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *WeightedCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Peek(key)
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness.
+func (c *WeightedCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Contains(key)
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *WeightedCache) Remove(key interface{}) (present bool) {
+	c.lock.Lock()
+	defer c.unlock()
+	return c.lru.Remove(key)
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *WeightedCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Keys()
+}
+
+// Len returns the number of items in the cache.
+func (c *WeightedCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// Cost returns the total cost of the entries in the cache.
+func (c *WeightedCache) Cost() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Cost()
+}
+
+// Purge is used to completely clear the cache.
+func (c *WeightedCache) Purge() {
+	c.lock.Lock()
+	defer c.unlock()
+	c.lru.Purge()
+}
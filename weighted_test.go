@@ -0,0 +1,57 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWeightedCache(t *testing.T) {
+	l, err := NewWeighted(100, func(k, v interface{}) int64 {
+		return int64(len(v.(string)))
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Add(g*100+i, "0123456789")
+				l.Get(g*100 + i - 1)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if l.Cost() != 100 || l.Len() != 10 || len(l.Keys()) != 10 {
+		t.Fatalf("bad cost: %v len: %v", l.Cost(), l.Len())
+	}
+	l.Purge()
+	if l.Cost() != 0 {
+		t.Fatalf("bad cost: %v", l.Cost())
+	}
+}
+
+// Test that the eviction callback may call back into the cache
+func TestWeightedCache_EvictCallback(t *testing.T) {
+	var l *WeightedCache
+	var evicted []interface{}
+	l, err := NewWeightedWithEvict(2, func(k, v interface{}) int64 {
+		return 1
+	}, func(k, v interface{}) {
+		evicted = append(evicted, k)
+		l.Len()
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Remove(2)
+	l.Purge()
+	if len(evicted) != 3 || evicted[0] != 1 || evicted[1] != 2 || evicted[2] != 3 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}